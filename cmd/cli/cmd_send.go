@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,7 +12,9 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 	"github.com/spf13/cobra"
 )
 
@@ -23,7 +27,9 @@ func sendCommand() *cobra.Command {
 		Use:   "send",
 		Short: "Send audio file to doorbell",
 		Long: `Send an audio file to the doorbell speaker. The CLI will automatically
-convert the audio to G.711 µ-law format using ffmpeg and upload it to the server.
+convert the audio to G.711 µ-law format and upload it to the server. WAV files
+are converted natively, other formats with ffmpeg; without ffmpeg, MP3, FLAC and
+Ogg Vorbis files are uploaded as is for the server to decode.
 The server handles session management automatically.`,
 		Example: `  doorbell-cli send -f message.mp3
   doorbell-cli send --file announcement.wav
@@ -43,14 +49,17 @@ func runSend(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("audio file not found: %s", audioFile)
 	}
 
-	// Check if ffmpeg is available
-	if _, err := exec.LookPath("ffmpeg"); err != nil {
-		return fmt.Errorf("ffmpeg not found in PATH. Please install ffmpeg")
-	}
-
-	// Convert audio file to G.711 µ-law
+	// Convert audio file to G.711 µ-law, natively if it is a WAV file we can read
 	log.Println("Converting audio file to G.711 µ-law...")
-	convertedData, err := convertToG711u(audioFile)
+	convertedData, err := convertWAV(audioFile)
+	if errors.Is(err, audio.ErrNotWAV) {
+		if _, lookErr := exec.LookPath("ffmpeg"); lookErr == nil {
+			convertedData, err = convertToG711u(audioFile)
+		} else {
+			// Without ffmpeg, the server decodes MP3, FLAC and Ogg Vorbis itself
+			convertedData, err = readServerDecodable(audioFile)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to convert audio: %w", err)
 	}
@@ -67,6 +76,38 @@ func runSend(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// convertWAV converts a WAV file to G.711 µ-law without ffmpeg.
+// It returns audio.ErrNotWAV for other files.
+func convertWAV(inputFile string) ([]byte, error) {
+	f, err := os.Open(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	wav, err := audio.ReadWAV(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("WAV file: %d channel(s) at %d Hz, %d bits (format %d), %s",
+		wav.Channels, wav.SampleRate, wav.BitsPerSample, wav.Format, wav.Duration().Round(time.Millisecond))
+	return wav.Encode(audio.PCMU)
+}
+
+// readServerDecodable returns the contents of a file the server can decode
+func readServerDecodable(inputFile string) ([]byte, error) {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if audio.SniffFormat(data) == audio.FileFormatRaw {
+		return nil, fmt.Errorf("ffmpeg not found in PATH. Please install ffmpeg")
+	}
+	log.Println("ffmpeg not found, uploading the file for the server to decode")
+	return data, nil
+}
+
 func convertToG711u(inputFile string) ([]byte, error) {
 	// Build ffmpeg command to convert to G.711 µ-law
 	args := []string{
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// alertHeartbeatInterval is how often an idle alertStream sends a "videoloss inactive" block, like the real device
+const alertHeartbeatInterval = 10 * time.Second
+
+// alertBoundary separates the parts of the alert stream
+const alertBoundary = "boundary"
+
+// alertHub fans out device events to every connected alertStream
+type alertHub struct {
+	mu          sync.Mutex
+	subscribers map[chan hikvision.EventNotificationAlert]struct{}
+}
+
+func newAlertHub() *alertHub {
+	return &alertHub{
+		subscribers: make(map[chan hikvision.EventNotificationAlert]struct{}),
+	}
+}
+
+func (h *alertHub) publish(alert hikvision.EventNotificationAlert) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		select {
+		case sub <- alert:
+		default:
+			log.Printf("[Alerts] Subscriber too slow, dropping %s event", alert.EventType)
+		}
+	}
+}
+
+func (h *alertHub) subscribe() (chan hikvision.EventNotificationAlert, func()) {
+	sub := make(chan hikvision.EventNotificationAlert, 8)
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub, func() {
+		h.mu.Lock()
+		delete(h.subscribers, sub)
+		h.mu.Unlock()
+	}
+}
+
+// handleAlertStream serves /ISAPI/Event/notification/alertStream as a never-ending multipart response
+func (h *alertHub) handleAlertStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, unsubscribe := h.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+alertBoundary)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "--%s\r\n", alertBoundary)
+	flusher.Flush()
+
+	log.Printf("[Alerts] Client connected from %s", r.RemoteAddr)
+
+	heartbeat := time.NewTicker(alertHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var alert hikvision.EventNotificationAlert
+		select {
+		case <-r.Context().Done():
+			log.Printf("[Alerts] Client %s disconnected", r.RemoteAddr)
+			return
+		case <-heartbeat.C:
+			alert = newAlert("videoloss", "inactive", "videoloss alarm")
+		case alert = <-sub:
+		}
+
+		if err := writeAlert(w, alert); err != nil {
+			log.Printf("[Alerts] Failed to write to %s: %v", r.RemoteAddr, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func newAlert(eventType, state, description string) hikvision.EventNotificationAlert {
+	return hikvision.EventNotificationAlert{
+		IPAddress:        "127.0.0.1",
+		ChannelID:        "1",
+		DateTime:         time.Now().Format(time.RFC3339),
+		ActivePostCount:  1,
+		EventType:        eventType,
+		EventState:       state,
+		EventDescription: description,
+	}
+}
+
+// writeAlert writes one part followed by the next boundary. Like the device, the
+// boundary is sent right away so the reader does not wait for the next event.
+func writeAlert(w io.Writer, alert hikvision.EventNotificationAlert) error {
+	body, err := xml.MarshalIndent(alert, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Content-Type: application/xml; charset=\"UTF-8\"\r\nContent-Length: %d\r\n\r\n%s\r\n--%s\r\n",
+		len(body), body, alertBoundary)
+	return err
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+)
+
+// toneAmplitude is the peak amplitude of the generated microphone tone (about -12 dBFS)
+const toneAmplitude = 8000
+
+// channelCodec returns the codec the fake device can stream for a channel codec
+func channelCodec(name string) (audio.Codec, bool) {
+	for _, c := range []audio.Codec{audio.PCMU, audio.PCMA, audio.G726} {
+		if strings.EqualFold(name, c.Name) {
+			return c, true
+		}
+	}
+	return audio.Codec{}, false
+}
+
+// toneEncoder returns an encoder of microphone frames for a channel codec.
+// G.726 is adaptive, so each stream needs its own encoder.
+func toneEncoder(codec audio.Codec) func([]int16) []byte {
+	if codec == audio.G726 {
+		return audio.NewG726Encoder().Encode
+	}
+	return func(samples []int16) []byte {
+		data, _ := audio.Encode(codec, samples)
+		return data
+	}
+}
+
+// handleAudioReceive streams the microphone side: a continuous sine tone paced in real time
+func (d *fakeDoorbell) handleAudioReceive(w http.ResponseWriter, r *http.Request) {
+	id, codec, done, ok := d.openChannel(w, r)
+	if !ok {
+		return
+	}
+	c, _ := channelCodec(codec)
+	encode := toneEncoder(c)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Audio] Channel %s: Microphone stream started (%.0f Hz tone, %s)", id, d.toneHz, codec)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(audio.SampleDuration)
+	defer ticker.Stop()
+
+	var disconnect <-chan time.Time
+	if d.faults.disconnectAfter > 0 {
+		disconnect = time.After(d.faults.disconnectAfter)
+	}
+
+	frame := make([]int16, audio.SampleRate*audio.SampleDuration/time.Second)
+	step := 2 * math.Pi * d.toneHz / audio.SampleRate
+	phase := 0.0
+	sent := 0
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("[Audio] Channel %s: Microphone stream ended by client after %d bytes", id, sent)
+			return
+		case <-done:
+			log.Printf("[Audio] Channel %s: Microphone stream ended, channel closed after %d bytes", id, sent)
+			return
+		case <-disconnect:
+			log.Printf("[Audio] Channel %s: Dropping microphone stream after %d bytes (injected fault)", id, sent)
+			panic(http.ErrAbortHandler)
+		case <-ticker.C:
+			for i := range frame {
+				frame[i] = int16(toneAmplitude * math.Sin(phase))
+				phase = math.Mod(phase+step, 2*math.Pi)
+			}
+			data := encode(frame)
+			if _, err := w.Write(data); err != nil {
+				log.Printf("[Audio] Channel %s: Microphone stream write failed: %v", id, err)
+				return
+			}
+			flusher.Flush()
+			sent += len(data)
+		}
+	}
+}
+
+// handleAudioSend receives the speaker side. Like the real device, it answers the
+// PUT right away and then reads raw audio from the connection until it closes.
+func (d *fakeDoorbell) handleAudioSend(w http.ResponseWriter, r *http.Request) {
+	id, codec, done, ok := d.openChannel(w, r)
+	if !ok {
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("[Audio] Channel %s: Hijack failed: %v", id, err)
+		return
+	}
+	defer conn.Close()
+
+	// The real device never reads a request body: audio follows the headers on the raw connection
+	rw.WriteString("HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: 0\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		log.Printf("[Audio] Channel %s: Failed to answer speaker stream: %v", id, err)
+		return
+	}
+
+	sink, path, err := d.openRecording(id, codec)
+	if err != nil {
+		log.Printf("[Audio] Channel %s: Failed to create recording: %v", id, err)
+		return
+	}
+	defer sink.Close()
+
+	log.Printf("[Audio] Channel %s: Speaker stream started (%s)", id, codec)
+	if path != "" {
+		log.Printf("[Audio] Channel %s: Recording to %s", id, path)
+	}
+
+	// Closing the connection unblocks the read loop below
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		var disconnect <-chan time.Time
+		if d.faults.disconnectAfter > 0 {
+			disconnect = time.After(d.faults.disconnectAfter)
+		}
+		select {
+		case <-stop:
+		case <-done:
+			log.Printf("[Audio] Channel %s: Channel closed, ending speaker stream", id)
+			conn.Close()
+		case <-disconnect:
+			log.Printf("[Audio] Channel %s: Dropping speaker stream (injected fault)", id)
+			conn.Close()
+		}
+	}()
+
+	var src io.Reader = rw.Reader
+	if d.faults.idleTimeout > 0 {
+		src = &idleReader{r: rw.Reader, conn: conn, timeout: d.faults.idleTimeout}
+	}
+
+	received, err := io.Copy(sink, src)
+	c, _ := channelCodec(codec)
+	duration := time.Duration(received) * audio.SampleDuration / time.Duration(c.FrameSize())
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		log.Printf("[Audio] Channel %s: Dropping idle speaker stream after %d bytes (%s) (injected fault)", id, received, duration)
+		return
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("[Audio] Channel %s: Speaker stream failed after %d bytes (%s): %v", id, received, duration, err)
+		return
+	}
+	log.Printf("[Audio] Channel %s: Speaker stream ended, received %d bytes (%s)", id, received, duration)
+}
+
+// openRecording creates the file speaker audio is written to, or a discarding sink if recording is disabled
+func (d *fakeDoorbell) openRecording(id, codec string) (io.WriteCloser, string, error) {
+	if d.recordDir == "" {
+		return nopWriteCloser{io.Discard}, "", nil
+	}
+
+	ext := "ulaw"
+	switch c, _ := channelCodec(codec); c {
+	case audio.PCMA:
+		ext = "alaw"
+	case audio.G726:
+		ext = "g726"
+	}
+	name := fmt.Sprintf("channel%s-%s.%s", id, time.Now().Format("20060102-150405"), ext)
+	path := filepath.Join(d.recordDir, name)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return f, path, nil
+}
+
+// idleReader fails a read once the connection has been silent for timeout
+type idleReader struct {
+	r       io.Reader
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.r.Read(p)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
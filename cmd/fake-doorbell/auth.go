@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// nonceLifetime is how long an issued nonce is accepted before the client is asked to re-authenticate
+const nonceLifetime = 5 * time.Minute
+
+// digestAuth implements RFC 2617 digest authentication (MD5, qop=auth) the way
+// Hikvision firmware does, including its habit of answering some
+// unauthenticated requests with a 401 that carries no WWW-Authenticate header
+type digestAuth struct {
+	realm              string
+	username           string
+	password           string
+	emptyChallengeRate float64 // Fraction of challenges sent without a WWW-Authenticate header
+
+	mu     sync.Mutex
+	nonces map[string]time.Time // Issued nonce -> expiry
+}
+
+func newDigestAuth(realm, username, password string, emptyChallengeRate float64) *digestAuth {
+	return &digestAuth{
+		realm:              realm,
+		username:           username,
+		password:           password,
+		emptyChallengeRate: emptyChallengeRate,
+		nonces:             make(map[string]time.Time),
+	}
+}
+
+// Middleware rejects requests that do not carry valid digest credentials
+func (a *digestAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			if a.emptyChallengeRate > 0 && mrand.Float64() < a.emptyChallengeRate {
+				log.Printf("[Auth] %s %s: Sending 401 without challenge (injected fault)", r.Method, r.URL.Path)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			a.challenge(w, false)
+			return
+		}
+
+		stale, err := a.verify(r.Method, header)
+		if err != nil {
+			log.Printf("[Auth] %s %s: %v", r.Method, r.URL.Path, err)
+			a.challenge(w, stale)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// challenge issues a fresh nonce in a 401 response
+func (a *digestAuth) challenge(w http.ResponseWriter, stale bool) {
+	nonce := a.newNonce()
+	value := fmt.Sprintf(`Digest qop="auth", realm="%s", nonce="%s", stale="%t"`, a.realm, nonce, stale)
+	w.Header().Set("WWW-Authenticate", value)
+	writeStatus(w, http.StatusUnauthorized, statusInvalidOperation, "Invalid Operation", "badAuthorization")
+}
+
+func (a *digestAuth) newNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for n, expiry := range a.nonces {
+		if now.After(expiry) {
+			delete(a.nonces, n)
+		}
+	}
+	a.nonces[nonce] = now.Add(nonceLifetime)
+	return nonce
+}
+
+// verify checks an Authorization header, reporting whether a failure was only due to an expired nonce
+func (a *digestAuth) verify(method, header string) (bool, error) {
+	scheme, params, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Digest") {
+		return false, fmt.Errorf("unsupported authorization scheme")
+	}
+	p := parseDigestParams(params)
+
+	a.mu.Lock()
+	expiry, known := a.nonces[p["nonce"]]
+	a.mu.Unlock()
+	if !known || time.Now().After(expiry) {
+		return true, fmt.Errorf("unknown or expired nonce")
+	}
+
+	if p["username"] != a.username || p["realm"] != a.realm {
+		return false, fmt.Errorf("invalid credentials for user %q", p["username"])
+	}
+
+	ha1 := md5Hex(a.username + ":" + a.realm + ":" + a.password)
+	ha2 := md5Hex(method + ":" + p["uri"])
+
+	var expected string
+	if p["qop"] == "" {
+		expected = md5Hex(ha1 + ":" + p["nonce"] + ":" + ha2)
+	} else {
+		expected = md5Hex(ha1 + ":" + p["nonce"] + ":" + p["nc"] + ":" + p["cnonce"] + ":" + p["qop"] + ":" + ha2)
+	}
+	if p["response"] != expected {
+		return false, fmt.Errorf("invalid credentials for user %q", p["username"])
+	}
+	return false, nil
+}
+
+// parseDigestParams splits the comma-separated key=value pairs of a digest header
+func parseDigestParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, s = rest[1:], ""
+			} else {
+				value, s = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			value, s, _ = strings.Cut(rest, ",")
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// callStatus and callSignal mirror the JSON bodies of the VideoIntercom endpoints
+type callStatus struct {
+	CallStatus struct {
+		Status hikvision.CallState `json:"status"`
+	} `json:"CallStatus"`
+}
+
+type callSignal struct {
+	CallSignal struct {
+		CmdType hikvision.CallCommand `json:"cmdType"`
+	} `json:"CallSignal"`
+}
+
+// ring starts a call, as if a visitor pressed the button, and publishes the ring event
+func (d *fakeDoorbell) ring() {
+	d.mu.Lock()
+	d.callState = hikvision.CallStateRinging
+	d.recordClip()
+	d.mu.Unlock()
+
+	log.Printf("[Call] Doorbell ring")
+	d.alerts.publish(newAlert("doorbellRing", "active", "Doorbell ring"))
+}
+
+func (d *fakeDoorbell) handleCallStatus(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var status callStatus
+	status.CallStatus.Status = d.callState
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (d *fakeDoorbell) handleCallSignal(w http.ResponseWriter, r *http.Request) {
+	var signal callSignal
+	if err := json.NewDecoder(r.Body).Decode(&signal); err != nil {
+		writeJSONStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "badJsonContent")
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cmd := signal.CallSignal.CmdType
+	next := d.callState
+	switch {
+	case cmd == hikvision.CallCommandAnswer && d.callState == hikvision.CallStateRinging:
+		next = hikvision.CallStateOnCall
+	case cmd == hikvision.CallCommandReject && d.callState == hikvision.CallStateRinging,
+		cmd == hikvision.CallCommandHangUp && d.callState != hikvision.CallStateIdle:
+		next = hikvision.CallStateIdle
+	default:
+		log.Printf("[Call] Refusing %s while %s", cmd, d.callState)
+		writeJSONStatus(w, http.StatusBadRequest, statusInvalidOperation, "Invalid Operation", "invalidOperation")
+		return
+	}
+
+	log.Printf("[Call] %s: %s -> %s", cmd, d.callState, next)
+	d.callState = next
+	writeJSONStatus(w, http.StatusOK, statusOK, "OK", "ok")
+}
+
+// writeJSON writes v as a JSON response body
+func writeJSON(w http.ResponseWriter, httpStatus int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONStatus writes an ISAPI ResponseStatus as JSON, as format=json endpoints do
+func writeJSONStatus(w http.ResponseWriter, httpStatus, statusCode int, statusString, subStatusCode string) {
+	writeJSON(w, httpStatus, hikvision.ResponseStatus{
+		StatusCode:    statusCode,
+		StatusString:  statusString,
+		SubStatusCode: subStatusCode,
+	})
+}
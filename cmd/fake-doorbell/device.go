@@ -0,0 +1,363 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// ISAPI statusCode values used in ResponseStatus bodies
+const (
+	statusOK               = 1
+	statusDeviceBusy       = 2
+	statusInvalidOperation = 4
+	statusInvalidContent   = 6
+)
+
+// supportedCodecs are the two-way audio codecs the fake device can encode and record
+var supportedCodecs = []string{audio.PCMU.Name, audio.PCMA.Name, audio.G726.Name}
+
+// channel is the state of one two-way audio channel
+type channel struct {
+	id        string
+	codec     string
+	open      bool
+	sessionID int
+	done      chan struct{} // Closed when the channel is closed, ending its audio streams
+}
+
+// faults configures the failures the fake device injects
+type faults struct {
+	busyChannels    map[string]bool // Channels held by another client: listed as free, but opening fails
+	disconnectAfter time.Duration   // Drop audioData streams after this long (0 disables)
+	idleTimeout     time.Duration   // Drop speaker streams that send nothing for this long (0 disables)
+}
+
+// fakeDoorbell emulates the subset of ISAPI used by the server
+type fakeDoorbell struct {
+	info      hikvision.DeviceInfo
+	toneHz    float64
+	recordDir string
+	faults    faults
+	alerts    *alertHub
+
+	mu          sync.Mutex
+	channels    map[string]*channel
+	nextSession int
+	audioIn     hikvision.AudioIn  // Microphone settings of audio channel 1
+	audioOut    hikvision.AudioOut // Speaker settings of audio channel 1
+	callState   hikvision.CallState
+	clips       []clip // Recordings on the SD card, one per ring
+}
+
+func newFakeDoorbell(channelCount int, codec string, toneHz float64, recordDir string, f faults) *fakeDoorbell {
+	d := &fakeDoorbell{
+		info: hikvision.DeviceInfo{
+			DeviceName:           "Fake Doorbell",
+			DeviceID:             "00000000-0000-0000-0000-000000000000",
+			Model:                "DS-KV6113-FAKE",
+			SerialNumber:         "FAKE0000000000000000",
+			MACAddress:           "00:00:5e:00:53:01",
+			FirmwareVersion:      "V0.0.0",
+			FirmwareReleasedDate: "build 000000",
+			DeviceType:           "VIS",
+		},
+		toneHz:      toneHz,
+		recordDir:   recordDir,
+		faults:      f,
+		alerts:      newAlertHub(),
+		channels:    make(map[string]*channel),
+		nextSession: 1,
+		callState:   hikvision.CallStateIdle,
+	}
+	d.audioIn.ID = hikvision.DefaultAudioChannel
+	d.audioIn.SetVolume(50)
+	d.audioIn.NoiseReduce = "true"
+	d.audioOut.ID = hikvision.DefaultAudioChannel
+	d.audioOut.SetVolume(50)
+	d.audioOut.SetTalkVolume(50)
+
+	for i := 1; i <= channelCount; i++ {
+		id := fmt.Sprint(i)
+		d.channels[id] = &channel{id: id, codec: codec}
+	}
+	return d
+}
+
+// RegisterRoutes registers the ISAPI endpoints
+func (d *fakeDoorbell) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/ISAPI/System/deviceInfo", d.handleDeviceInfo).Methods("GET")
+	router.HandleFunc("/ISAPI/System/Audio/capabilities", d.handleAudioCapabilities).Methods("GET")
+	router.HandleFunc("/ISAPI/System/Audio/AudioIn/channels/{id}", d.handleAudioIn).Methods("GET", "PUT")
+	router.HandleFunc("/ISAPI/System/Audio/AudioOut/channels/{id}", d.handleAudioOut).Methods("GET", "PUT")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels", d.handleChannels).Methods("GET")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/capabilities", d.handleCapabilities).Methods("GET")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}", d.handleGetChannel).Methods("GET")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}", d.handlePutChannel).Methods("PUT")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}/open", d.handleOpen).Methods("PUT")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}/close", d.handleClose).Methods("PUT")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}/audioData", d.handleAudioReceive).Methods("GET")
+	router.HandleFunc("/ISAPI/System/TwoWayAudio/channels/{id}/audioData", d.handleAudioSend).Methods("PUT")
+	router.HandleFunc("/ISAPI/VideoIntercom/callStatus", d.handleCallStatus).Methods("GET")
+	router.HandleFunc("/ISAPI/VideoIntercom/callSignal", d.handleCallSignal).Methods("PUT")
+	router.HandleFunc("/ISAPI/ContentMgmt/search", d.handleSearch).Methods("POST")
+	router.HandleFunc("/ISAPI/ContentMgmt/download", d.handleDownload).Methods("GET")
+	router.HandleFunc("/ISAPI/Event/notification/alertStream", d.alerts.handleAlertStream).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[ISAPI] %s %s: Not supported", r.Method, r.URL.Path)
+		writeStatus(w, http.StatusNotFound, statusInvalidOperation, "Invalid Operation", "notSupport")
+	})
+}
+
+// channelInfo returns the ISAPI representation of a channel; d.mu must be held
+func (d *fakeDoorbell) channelInfo(ch *channel) hikvision.TwoWayAudioChannel {
+	return hikvision.TwoWayAudioChannel{
+		ID:                   ch.id,
+		Enabled:              fmt.Sprint(ch.open),
+		AudioInputID:         "1",
+		AudioOutputID:        "1",
+		AudioCompressionType: ch.codec,
+	}
+}
+
+// lookup returns the channel named in the request path, writing a 404 if it does not exist
+func (d *fakeDoorbell) lookup(w http.ResponseWriter, r *http.Request) (*channel, bool) {
+	ch, ok := d.channels[mux.Vars(r)["id"]]
+	if !ok {
+		writeStatus(w, http.StatusNotFound, statusInvalidOperation, "Invalid Operation", "invalidID")
+	}
+	return ch, ok
+}
+
+func (d *fakeDoorbell) sortedChannels() []*channel {
+	ids := make([]string, 0, len(d.channels))
+	for id := range d.channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	channels := make([]*channel, 0, len(ids))
+	for _, id := range ids {
+		channels = append(channels, d.channels[id])
+	}
+	return channels
+}
+
+func (d *fakeDoorbell) handleDeviceInfo(w http.ResponseWriter, r *http.Request) {
+	writeXML(w, d.info)
+}
+
+func (d *fakeDoorbell) handleAudioCapabilities(w http.ResponseWriter, r *http.Request) {
+	writeXML(w, hikvision.AudioCapabilities{AudioInputNums: 1, AudioOutputNums: 1})
+}
+
+func (d *fakeDoorbell) handleAudioIn(w http.ResponseWriter, r *http.Request) {
+	var update hikvision.AudioIn
+	if !d.audioSettings(w, r, &update) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if r.Method == "PUT" {
+		log.Printf("[ISAPI] Microphone: Volume %d -> %d, noise reduction %s", d.audioIn.Volume(), update.Volume(), update.NoiseReduce)
+		d.audioIn = update
+		writeStatus(w, http.StatusOK, statusOK, "OK", "ok")
+		return
+	}
+	writeXML(w, d.audioIn)
+}
+
+func (d *fakeDoorbell) handleAudioOut(w http.ResponseWriter, r *http.Request) {
+	var update hikvision.AudioOut
+	if !d.audioSettings(w, r, &update) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if r.Method == "PUT" {
+		log.Printf("[ISAPI] Speaker: Volume %d -> %d", d.audioOut.Volume(), update.Volume())
+		d.audioOut = update
+		writeStatus(w, http.StatusOK, statusOK, "OK", "ok")
+		return
+	}
+	writeXML(w, d.audioOut)
+}
+
+// audioSettings validates the channel of an audio settings request and decodes the body of a PUT into v
+func (d *fakeDoorbell) audioSettings(w http.ResponseWriter, r *http.Request, v any) bool {
+	if mux.Vars(r)["id"] != hikvision.DefaultAudioChannel {
+		writeStatus(w, http.StatusNotFound, statusInvalidOperation, "Invalid Operation", "invalidID")
+		return false
+	}
+	if r.Method != "PUT" {
+		return true
+	}
+	if err := xml.NewDecoder(r.Body).Decode(v); err != nil {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid XML Content", "badXmlContent")
+		return false
+	}
+	return true
+}
+
+func (d *fakeDoorbell) handleChannels(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var list hikvision.TwoWayAudioChannelList
+	for _, ch := range d.sortedChannels() {
+		list.Channels = append(list.Channels, d.channelInfo(ch))
+	}
+	writeXML(w, list)
+}
+
+func (d *fakeDoorbell) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var caps hikvision.TwoWayAudioCapabilities
+	for _, ch := range d.sortedChannels() {
+		caps.Channels = append(caps.Channels, hikvision.TwoWayAudioChannelCap{
+			ID: ch.id,
+			AudioCompressionType: hikvision.OptionValue{
+				Value: ch.codec,
+				Opt:   strings.Join(supportedCodecs, ","),
+			},
+		})
+	}
+	writeXML(w, caps)
+}
+
+func (d *fakeDoorbell) handleGetChannel(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeXML(w, d.channelInfo(ch))
+}
+
+func (d *fakeDoorbell) handlePutChannel(w http.ResponseWriter, r *http.Request) {
+	var update hikvision.TwoWayAudioChannel
+	if err := xml.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid XML Content", "badXmlContent")
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := channelCodec(update.AudioCompressionType); !ok {
+		log.Printf("[ISAPI] Channel %s: Codec %q not supported", ch.id, update.AudioCompressionType)
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "notSupport")
+		return
+	}
+
+	log.Printf("[ISAPI] Channel %s: Codec %s -> %s", ch.id, ch.codec, update.AudioCompressionType)
+	ch.codec = update.AudioCompressionType
+	writeStatus(w, http.StatusOK, statusOK, "OK", "ok")
+}
+
+func (d *fakeDoorbell) handleOpen(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	if ch.open || d.faults.busyChannels[ch.id] {
+		log.Printf("[ISAPI] Channel %s: Open refused, channel busy", ch.id)
+		writeStatus(w, http.StatusForbidden, statusDeviceBusy, "Device Busy", "deviceBusy")
+		return
+	}
+
+	ch.open = true
+	ch.sessionID = d.nextSession
+	ch.done = make(chan struct{})
+	d.nextSession++
+
+	log.Printf("[ISAPI] Channel %s: Opened, session %d (%s)", ch.id, ch.sessionID, ch.codec)
+	writeXML(w, hikvision.TwoWayAudioSession{SessionID: fmt.Sprint(ch.sessionID)})
+}
+
+func (d *fakeDoorbell) handleClose(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	if ch.open {
+		close(ch.done)
+		ch.open = false
+		log.Printf("[ISAPI] Channel %s: Closed session %d", ch.id, ch.sessionID)
+	}
+	writeStatus(w, http.StatusOK, statusOK, "OK", "ok")
+}
+
+// openChannel returns the channel for an audioData request if it has an open session
+func (d *fakeDoorbell) openChannel(w http.ResponseWriter, r *http.Request) (id, codec string, done <-chan struct{}, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch, ok := d.lookup(w, r)
+	if !ok {
+		return "", "", nil, false
+	}
+	if !ch.open {
+		log.Printf("[ISAPI] Channel %s: audioData requested without an open session", ch.id)
+		writeStatus(w, http.StatusForbidden, statusInvalidOperation, "Invalid Operation", "invalidSession")
+		return "", "", nil, false
+	}
+	return ch.id, ch.codec, ch.done, true
+}
+
+// writeXML writes v as an XML response body
+func writeXML(w http.ResponseWriter, v any) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	w.Write(body)
+}
+
+// writeStatus writes an ISAPI ResponseStatus with the given HTTP status
+func writeStatus(w http.ResponseWriter, httpStatus, statusCode int, statusString, subStatusCode string) {
+	body, _ := xml.MarshalIndent(hikvision.ResponseStatus{
+		StatusCode:    statusCode,
+		StatusString:  statusString,
+		SubStatusCode: subStatusCode,
+	}, "", "  ")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(httpStatus)
+	io.WriteString(w, xml.Header)
+	w.Write(body)
+}
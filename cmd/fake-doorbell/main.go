@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "Address to listen on")
+	username := flag.String("username", "admin", "Digest auth username")
+	password := flag.String("password", "password", "Digest auth password")
+	realm := flag.String("realm", "DS-KV6113-FAKE", "Digest auth realm")
+	channels := flag.Int("channels", 1, "Number of two-way audio channels")
+	codec := flag.String("codec", audio.PCMU.Name, "Initial channel codec (G.711ulaw, G.711alaw or G.726)")
+	toneHz := flag.Float64("tone", 440, "Frequency of the microphone tone in Hz")
+	recordDir := flag.String("record-dir", "", "Directory to record received speaker audio to (disabled if empty)")
+	ringInterval := flag.Duration("ring-interval", 0, "Send a doorbell ring event at this interval (0 disables)")
+
+	// Fault injection
+	emptyChallengeRate := flag.Float64("empty-challenge-rate", 0, "Fraction of auth challenges sent as a 401 without WWW-Authenticate (0-1)")
+	busyChannels := flag.String("busy-channels", "", "Comma-separated channel IDs that are listed as free but refuse to open")
+	disconnectAfter := flag.Duration("disconnect-after", 0, "Drop audioData streams after this long (0 disables)")
+	idleTimeout := flag.Duration("idle-timeout", 0, "Drop speaker streams that send no audio for this long, like some firmware (0 disables)")
+	flag.Parse()
+
+	if _, ok := channelCodec(*codec); !ok {
+		log.Fatalf("Unsupported codec %q (use G.711ulaw, G.711alaw or G.726)", *codec)
+	}
+	if *channels < 1 {
+		log.Fatalf("At least one channel is required")
+	}
+	if *recordDir != "" {
+		if err := os.MkdirAll(*recordDir, 0755); err != nil {
+			log.Fatalf("Failed to create record directory: %v", err)
+		}
+	}
+
+	f := faults{
+		busyChannels:    make(map[string]bool),
+		disconnectAfter: *disconnectAfter,
+		idleTimeout:     *idleTimeout,
+	}
+	for _, id := range strings.Split(*busyChannels, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			f.busyChannels[id] = true
+		}
+	}
+
+	doorbell := newFakeDoorbell(*channels, *codec, *toneHz, *recordDir, f)
+	auth := newDigestAuth(*realm, *username, *password, *emptyChallengeRate)
+
+	isapi := mux.NewRouter()
+	doorbell.RegisterRoutes(isapi)
+
+	// Control endpoints are not part of ISAPI and need no auth
+	router := mux.NewRouter()
+	router.HandleFunc("/fake/ring", func(w http.ResponseWriter, r *http.Request) {
+		doorbell.ring()
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("POST")
+	router.PathPrefix("/").Handler(auth.Middleware(isapi))
+
+	if *ringInterval > 0 {
+		go func() {
+			for range time.Tick(*ringInterval) {
+				doorbell.ring()
+			}
+		}()
+	}
+
+	log.Printf("Fake doorbell listening on %s (user %q, %d channel(s), %s)", *addr, *username, *channels, *codec)
+	if *emptyChallengeRate > 0 || len(f.busyChannels) > 0 || f.disconnectAfter > 0 || f.idleTimeout > 0 {
+		log.Printf("Fault injection: empty challenges %.0f%%, busy channels %v, disconnect after %s, idle timeout %s",
+			*emptyChallengeRate*100, strings.Split(*busyChannels, ","), f.disconnectAfter, f.idleTimeout)
+	}
+
+	if err := http.ListenAndServe(*addr, router); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
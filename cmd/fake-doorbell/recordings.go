@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clipDuration is the length of the clip recorded for each ring
+	clipDuration = 10 * time.Second
+
+	// clipSize is the size of the placeholder content served for a clip
+	clipSize = 64 * 1024
+
+	// ringMetadata is the metadata descriptor of the clips recorded on ring
+	ringMetadata = "//recordType.meta.std-cgi.com/allEvent"
+)
+
+// clip is a recording on the fake SD card
+type clip struct {
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+// searchDescription and searchResult mirror the XML bodies of /ISAPI/ContentMgmt/search
+type searchDescription struct {
+	SearchID   string   `xml:"searchID"`
+	TrackIDs   []string `xml:"trackList>trackID"`
+	StartTime  string   `xml:"timeSpanList>timeSpan>startTime"`
+	EndTime    string   `xml:"timeSpanList>timeSpan>endTime"`
+	MaxResults int      `xml:"maxResults"`
+	Position   int      `xml:"searchResultPostion"`
+	Metadata   string   `xml:"metadataList>metadataDescriptor"`
+}
+
+type searchMatch struct {
+	SourceID    string `xml:"sourceID"`
+	TrackID     string `xml:"trackID"`
+	StartTime   string `xml:"timeSpan>startTime"`
+	EndTime     string `xml:"timeSpan>endTime"`
+	ContentType string `xml:"mediaSegmentDescriptor>contentType"`
+	CodecType   string `xml:"mediaSegmentDescriptor>codecType"`
+	PlaybackURI string `xml:"mediaSegmentDescriptor>playbackURI"`
+	Metadata    string `xml:"metadataMatches>metadataDescriptor"`
+}
+
+type searchResult struct {
+	XMLName      xml.Name      `xml:"CMSearchResult"`
+	SearchID     string        `xml:"searchID"`
+	Status       bool          `xml:"responseStatus"`
+	StatusString string        `xml:"responseStatusStrg"`
+	NumOfMatches int           `xml:"numOfMatches"`
+	Matches      []searchMatch `xml:"matchList>searchMatchItem"`
+}
+
+// recordClip stores a clip for a ring, as doorbells do on their SD card. Called with d.mu held.
+func (d *fakeDoorbell) recordClip() {
+	now := time.Now().UTC().Truncate(time.Second)
+	d.clips = append(d.clips, clip{
+		name:  fmt.Sprintf("%011d", len(d.clips)+1),
+		start: now,
+		end:   now.Add(clipDuration),
+	})
+}
+
+func (d *fakeDoorbell) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var search searchDescription
+	if err := xml.NewDecoder(r.Body).Decode(&search); err != nil {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "badXmlContent")
+		return
+	}
+	from, err1 := time.Parse(time.RFC3339, search.StartTime)
+	to, err2 := time.Parse(time.RFC3339, search.EndTime)
+	if err1 != nil || err2 != nil || search.MaxResults <= 0 {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "badParameters")
+		return
+	}
+
+	d.mu.Lock()
+	var matches []searchMatch
+	// Clips are only recorded on ring, so continuous, motion and alarm searches find nothing
+	if strings.HasPrefix(ringMetadata, search.Metadata) {
+		for _, c := range d.clips {
+			if c.end.Before(from) || c.start.After(to) {
+				continue
+			}
+			matches = append(matches, searchMatch{
+				SourceID:    "{0000000000-0000-0000-0000-000000000000}",
+				TrackID:     "101",
+				StartTime:   c.start.Format(time.RFC3339),
+				EndTime:     c.end.Format(time.RFC3339),
+				ContentType: "video",
+				CodecType:   "H.264-BP",
+				PlaybackURI: d.playbackURI(r, c),
+				Metadata:    ringMetadata,
+			})
+		}
+	}
+	d.mu.Unlock()
+
+	result := searchResult{
+		SearchID:     search.SearchID,
+		Status:       true,
+		StatusString: "NO MATCHES",
+	}
+	if search.Position < len(matches) {
+		page := matches[search.Position:]
+		result.StatusString = "OK"
+		if len(page) > search.MaxResults {
+			page = page[:search.MaxResults]
+			result.StatusString = "MORE"
+		}
+		result.Matches = page
+		result.NumOfMatches = len(page)
+	}
+	writeXML(w, result)
+}
+
+// playbackURI returns the URI identifying a clip, in the device's format
+func (d *fakeDoorbell) playbackURI(r *http.Request, c clip) string {
+	host, _, _ := strings.Cut(r.Host, ":")
+	query := url.Values{}
+	query.Set("starttime", c.start.Format("20060102T150405Z"))
+	query.Set("endtime", c.end.Format("20060102T150405Z"))
+	query.Set("name", c.name)
+	query.Set("size", strconv.Itoa(clipSize))
+	return "rtsp://" + host + "/Streaming/tracks/101/?" + query.Encode()
+}
+
+func (d *fakeDoorbell) handleDownload(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlaybackURI string `xml:"playbackURI"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "badXmlContent")
+		return
+	}
+	u, err := url.Parse(req.PlaybackURI)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, statusInvalidContent, "Invalid Content", "badParameters")
+		return
+	}
+
+	d.mu.Lock()
+	found := false
+	for _, c := range d.clips {
+		if c.name == u.Query().Get("name") {
+			found = true
+			break
+		}
+	}
+	d.mu.Unlock()
+	if !found {
+		writeStatus(w, http.StatusNotFound, statusInvalidOperation, "Invalid Operation", "noRecordFound")
+		return
+	}
+
+	// Placeholder content, the fake has no camera
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Length", strconv.Itoa(clipSize))
+	w.Write(make([]byte, clipSize))
+}
@@ -2,20 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/acardace/hikvision-doorbell-server/internal/api"
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+	"github.com/acardace/hikvision-doorbell-server/internal/audit"
 	"github.com/acardace/hikvision-doorbell-server/internal/config"
+	"github.com/acardace/hikvision-doorbell-server/internal/events"
 	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/acardace/hikvision-doorbell-server/internal/streaming"
+	"github.com/acardace/hikvision-doorbell-server/internal/tts"
 )
 
+// configPollInterval is how often the configuration file is checked for changes
+const configPollInterval = 10 * time.Second
+
 func main() {
 	configPath := flag.String("config", "config.yaml", "Path to configuration file")
 	flag.Parse()
@@ -26,32 +36,84 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Create Hikvision client
-	hikClient := hikvision.NewClient(
-		cfg.Hikvision.Host,
-		cfg.Hikvision.Username,
-		cfg.Hikvision.Password,
-	)
+	// Open audit log for security-relevant actions (door unlock)
+	auditLog, err := audit.New(cfg.Audit.Path)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
 
-	// Test connection by getting channels
-	log.Println("Testing connection to Hikvision device...")
-	channelList, err := hikClient.GetTwoWayAudioChannels()
+	// WebRTC configuration (and its UDP port) is shared by all devices
+	webrtcConfig := api.NewWebRTCConfig()
+	webrtcConfig.LoadFromEnv()
+
+	trustedProxies, err := api.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		log.Fatalf("Invalid server configuration: %v", err)
+	}
+	if cfg.Server.DoorToken == "" {
+		log.Println("Door unlock is disabled: set server.door_token to enable it")
+	}
+
+	// Text-to-speech (and its phrase cache) is shared by all devices
+	ttsCache, err := newTTS(cfg.TTS)
 	if err != nil {
-		log.Fatalf("Failed to connect to Hikvision device: %v", err)
+		log.Fatalf("Failed to set up text-to-speech: %v", err)
 	}
-	log.Printf("Found %d two-way audio channels", len(channelList.Channels))
 
-	for _, c := range channelList.Channels {
-		if c.Enabled == "true" {
-			if err := hikClient.CloseAudioChannel(c.ID); err != nil {
-				log.Fatalf("Cannot re-initiliaze hikvision device")
+	// Device events are streamed until shutdown
+	eventCtx, stopEvents := context.WithCancel(context.Background())
+
+	// Create a handler per device
+	handlers := make([]*api.Handler, 0, len(cfg.Devices))
+	clients := make(map[string]*hikvision.Client, len(cfg.Devices))
+	for _, device := range cfg.Devices {
+		hikClient := connectDevice(device)
+		clients[device.Name] = hikClient
+
+		// Subscribe to device events (doorbell ring, motion, tamper)
+		eventBus := events.NewBus()
+		eventSource := events.NewHikvisionEventSource(device.Name, hikClient, eventBus)
+		go eventSource.Run(eventCtx)
+
+		handlers = append(handlers, api.NewHandler(hikClient, api.HandlerConfig{
+			Name:           device.Name,
+			Codec:          device.Codec,
+			WebRTC:         webrtcConfig,
+			EventBus:       eventBus,
+			AuditLog:       auditLog,
+			AutoAnswer:     device.AutoAnswer,
+			Video:          hikvision.VideoStream(device.Video),
+			RTSPPort:       device.RTSPPort,
+			Processing:     audioProcessing(device.AudioProcessing),
+			Echo:           echoSuppression(device.EchoSuppression),
+			TTS:            ttsCache,
+			DoorToken:      cfg.Server.DoorToken,
+			TrustedProxies: trustedProxies,
+		}))
+	}
+	log.Printf("Serving %d device(s), default device: %s", len(handlers), cfg.DefaultDevice)
+
+	// Pick up credential changes, which also lifts an auth lockout.
+	// Other settings require a restart.
+	credentials := make(map[string][2]string, len(cfg.Devices))
+	for _, device := range cfg.Devices {
+		credentials[device.Name] = [2]string{device.Username, device.Password}
+	}
+	go config.Watch(eventCtx, *configPath, configPollInterval, func(newCfg *config.Config) {
+		for _, device := range newCfg.Devices {
+			hikClient, ok := clients[device.Name]
+			creds := [2]string{device.Username, device.Password}
+			if !ok || credentials[device.Name] == creds {
+				continue
 			}
+			credentials[device.Name] = creds
+			log.Printf("[%s] Reloading credentials", device.Name)
+			hikClient.SetCredentials(device.Username, device.Password)
 		}
-	}
+	})
 
-	// Create API handler
-	handler := api.NewHandler(hikClient)
-	router := handler.SetupRoutes()
+	router := api.SetupRoutes(handlers, cfg.DefaultDevice)
 
 	// Setup HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -75,9 +137,14 @@ func main() {
 	<-sigChan
 	log.Println("\nShutdown signal received, cleaning up...")
 
+	// Stop the device event stream
+	stopEvents()
+
 	// Close any active sessions
-	if err := handler.CloseAllSessions(); err != nil {
-		log.Printf("Warning: Error closing sessions: %v", err)
+	for _, handler := range handlers {
+		if err := handler.CloseAllSessions(); err != nil {
+			log.Printf("Warning: Error closing sessions: %v", err)
+		}
 	}
 
 	// Shutdown HTTP server with timeout
@@ -90,3 +157,138 @@ func main() {
 
 	log.Println("Server stopped")
 }
+
+// connectDevice creates the client for a device, checks the connection and
+// closes any audio channel left open by a previous run
+func connectDevice(device config.DeviceConfig) *hikvision.Client {
+	if device.Codec != "" {
+		if _, ok := audio.CodecByName(device.Codec); !ok {
+			log.Fatalf("[%s] Unsupported codec %q (use G.711ulaw, G.711alaw, G.722 or G.726)", device.Name, device.Codec)
+		}
+	}
+
+	if err := audioProcessing(device.AudioProcessing).Validate(); err != nil {
+		log.Fatalf("[%s] Invalid audio_processing: %v", device.Name, err)
+	}
+	if err := echoSuppression(device.EchoSuppression).Validate(); err != nil {
+		log.Fatalf("[%s] Invalid echo_suppression: %v", device.Name, err)
+	}
+
+	switch hikvision.VideoStream(device.Video) {
+	case "", hikvision.VideoStreamMain, hikvision.VideoStreamSub:
+	default:
+		log.Fatalf("[%s] Unsupported video stream %q (use main or sub)", device.Name, device.Video)
+	}
+
+	// Create Hikvision client
+	hikClient, err := hikvision.NewClient(
+		device.Host,
+		device.Username,
+		device.Password,
+		hikvision.ClientOptions{
+			Scheme:               device.Scheme,
+			Port:                 device.Port,
+			CAFile:               device.CAFile,
+			PinnedFingerprints:   device.PinnedFingerprints,
+			RequestTimeout:       device.RequestTimeout,
+			StreamConnectTimeout: device.StreamConnectTimeout,
+			AuthMaxFailures:      device.AuthMaxFailures,
+			AuthBackoff:          device.AuthBackoff,
+		},
+	)
+	if err != nil {
+		log.Fatalf("[%s] Failed to create Hikvision client: %v", device.Name, err)
+	}
+
+	ctx := context.Background()
+
+	// Test connection by getting channels
+	log.Printf("[%s] Testing connection to Hikvision device...", device.Name)
+	channelList, err := hikClient.GetTwoWayAudioChannels(ctx)
+	if errors.Is(err, hikvision.ErrUnauthorized) || errors.Is(err, hikvision.ErrDeviceLocked) {
+		// Exiting would make a restart loop retry the bad credentials until the account locks
+		log.Printf("[%s] Warning: Device rejected the credentials, fix them in the configuration: %v", device.Name, err)
+		return hikClient
+	}
+	if err != nil {
+		// Keep serving the other devices; this one's requests fail until it is back
+		log.Printf("[%s] Warning: Failed to connect to Hikvision device, its requests will fail until it is reachable: %v", device.Name, err)
+		return hikClient
+	}
+	log.Printf("[%s] Found %d two-way audio channels", device.Name, len(channelList.Channels))
+
+	// Log device identity for support purposes (not every model supports this)
+	if info, err := hikClient.GetDeviceInfo(ctx); err == nil {
+		log.Printf("[%s] Device: %s (serial %s), firmware %s %s", device.Name,
+			info.Model, info.SerialNumber, info.FirmwareVersion, info.FirmwareReleasedDate)
+	} else {
+		log.Printf("[%s] Warning: Could not query device info: %v", device.Name, err)
+	}
+	if caps, err := hikClient.GetTwoWayAudioCapabilities(ctx); err == nil {
+		log.Printf("[%s] Supported two-way audio codecs: %v", device.Name, caps.Codecs())
+	}
+
+	for _, c := range channelList.Channels {
+		if c.Enabled == "true" {
+			if err := hikClient.CloseAudioChannel(ctx, c.ID); err != nil {
+				log.Printf("[%s] Warning: Cannot re-initialize audio channel %s of the Hikvision device: %v", device.Name, c.ID, err)
+			}
+		}
+	}
+
+	return hikClient
+}
+
+// audioProcessing converts the audio processing settings of a device
+func audioProcessing(cfg config.AudioProcessingConfig) audio.Processing {
+	return audio.Processing{
+		Normalize:      cfg.Normalize,
+		TargetLoudness: cfg.TargetLoudness,
+		Gain:           cfg.Gain,
+		Limiter:        cfg.Limiter,
+		LimiterCeiling: cfg.LimiterCeiling,
+	}
+}
+
+// echoSuppression converts the echo suppression settings of a device
+func echoSuppression(cfg config.EchoSuppressionConfig) streaming.EchoSuppression {
+	return streaming.EchoSuppression{
+		Enabled:          cfg.Enabled,
+		Threshold:        cfg.Threshold,
+		ReleaseThreshold: cfg.ReleaseThreshold,
+		Attenuation:      cfg.Attenuation,
+		HangTime:         cfg.HangTime,
+	}
+}
+
+// newTTS creates the configured text-to-speech provider behind a phrase cache,
+// or returns nil if none is configured
+func newTTS(cfg config.TTSConfig) (*tts.Cache, error) {
+	var provider tts.TTSProvider
+	switch {
+	case len(cfg.Command) > 0:
+		p, err := tts.NewCommandProvider(cfg.Command, cfg.Voice, cfg.Language, cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Text-to-speech: running %s", cfg.Command[0])
+		provider = p
+	case cfg.URL != "":
+		p, err := tts.NewHTTPProvider(cfg.URL, tts.HTTPProviderOptions{
+			Method:   cfg.Method,
+			Headers:  cfg.Headers,
+			Voice:    cfg.Voice,
+			Language: cfg.Language,
+			Timeout:  cfg.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		u, _ := url.Parse(cfg.URL)
+		log.Printf("Text-to-speech: calling %s", u.Redacted())
+		provider = p
+	default:
+		return nil, nil
+	}
+	return tts.NewCache(provider, cfg.CacheSize), nil
+}
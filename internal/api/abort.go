@@ -189,7 +189,7 @@ func (h *Handler) HandleAbort(w http.ResponseWriter, r *http.Request) {
 	// Abort all tracked operations and close all channels
 	if err := h.abortManager.AbortAll(r.Context()); err != nil {
 		log.Printf("[Abort] Error during abort: %v", err)
-		http.Error(w, "Failed to abort all operations", http.StatusInternalServerError)
+		http.Error(w, "Failed to abort all operations", deviceErrorStatus(err))
 		return
 	}
 
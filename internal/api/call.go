@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/gorilla/mux"
+)
+
+// callActions maps the call action routes to device commands
+var callActions = map[string]hikvision.CallCommand{
+	"answer": hikvision.CallCommandAnswer,
+	"hangup": hikvision.CallCommandHangUp,
+	"reject": hikvision.CallCommandReject,
+}
+
+// CallResponse is the JSON body returned by the call endpoints
+type CallResponse struct {
+	State hikvision.CallState `json:"state"`
+}
+
+// HandleCallStatus returns whether the doorbell is idle, ringing or in a call
+func (h *Handler) HandleCallStatus(w http.ResponseWriter, r *http.Request) {
+	state, err := h.hikClient.GetCallStatus(r.Context())
+	if err != nil {
+		log.Printf("[Call] Failed to get call status: %v", err)
+		http.Error(w, "Failed to get call status", deviceErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CallResponse{State: state})
+}
+
+// HandleCallAction answers, hangs up or rejects the doorbell call
+func (h *Handler) HandleCallAction(w http.ResponseWriter, r *http.Request) {
+	action := mux.Vars(r)["action"]
+	cmd, ok := callActions[action]
+	if !ok {
+		http.Error(w, "Unknown call action", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("[Call] Received %s request", action)
+
+	if err := h.hikClient.SendCallSignal(r.Context(), cmd); err != nil {
+		log.Printf("[Call] Failed to %s call: %v", action, err)
+		http.Error(w, "Failed to "+action+" call", deviceErrorStatus(err))
+		return
+	}
+
+	state, err := h.hikClient.GetCallStatus(r.Context())
+	if err != nil {
+		log.Printf("[Call] Failed to get call status: %v", err)
+		http.Error(w, "Failed to get call status", deviceErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CallResponse{State: state})
+}
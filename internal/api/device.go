@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// DeviceResponse is the JSON body returned by the device endpoint
+type DeviceResponse struct {
+	Name                string      `json:"name"`
+	Model               string      `json:"model"`
+	DeviceType          string      `json:"device_type"`
+	SerialNumber        string      `json:"serial_number"`
+	MACAddress          string      `json:"mac_address"`
+	FirmwareVersion     string      `json:"firmware_version"`
+	FirmwareReleaseDate string      `json:"firmware_release_date"`
+	Audio               DeviceAudio `json:"audio"`
+	StreamSetup         StreamSetup `json:"stream_setup"`
+}
+
+// DeviceAudio describes the audio capabilities of the device
+type DeviceAudio struct {
+	TwoWayChannels int      `json:"two_way_channels"`
+	Codecs         []string `json:"codecs"`
+	Inputs         int      `json:"inputs"`
+	Outputs        int      `json:"outputs"`
+}
+
+// StreamSetup reports how audio stream connections to the device were set up
+type StreamSetup struct {
+	Streams         int   `json:"streams"`
+	WarmConnections int   `json:"warm_connections"`
+	CachedNonces    int   `json:"cached_nonces"`
+	SavedLatencyMs  int64 `json:"saved_latency_ms"`
+}
+
+// HandleDevice returns the device model, firmware and audio capabilities
+func (h *Handler) HandleDevice(w http.ResponseWriter, r *http.Request) {
+	info, err := h.hikClient.GetDeviceInfo(r.Context())
+	if err != nil {
+		log.Printf("[Device] Failed to get device info: %v", err)
+		http.Error(w, "Failed to get device info", deviceErrorStatus(err))
+		return
+	}
+
+	resp := DeviceResponse{
+		Name:                info.DeviceName,
+		Model:               info.Model,
+		DeviceType:          info.DeviceType,
+		SerialNumber:        info.SerialNumber,
+		MACAddress:          info.MACAddress,
+		FirmwareVersion:     info.FirmwareVersion,
+		FirmwareReleaseDate: info.FirmwareReleasedDate,
+		Audio: DeviceAudio{
+			Codecs: []string{},
+		},
+	}
+
+	stats := h.hikClient.StreamSetupStats()
+	resp.StreamSetup = StreamSetup{
+		Streams:         stats.Streams,
+		WarmConnections: stats.WarmConnections,
+		CachedNonces:    stats.CachedNonces,
+		SavedLatencyMs:  stats.SavedLatency.Milliseconds(),
+	}
+
+	// Capabilities are best effort, not every model exposes them
+	if channels, err := h.hikClient.GetTwoWayAudioChannelsQuiet(r.Context()); err == nil {
+		resp.Audio.TwoWayChannels = len(channels.Channels)
+	}
+	if caps, err := h.hikClient.GetTwoWayAudioCapabilities(r.Context()); err == nil {
+		if codecs := caps.Codecs(); len(codecs) > 0 {
+			resp.Audio.Codecs = codecs
+		}
+	}
+	if caps, err := h.hikClient.GetAudioCapabilities(r.Context()); err == nil {
+		resp.Audio.Inputs = caps.AudioInputNums
+		resp.Audio.Outputs = caps.AudioOutputNums
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
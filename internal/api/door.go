@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audit"
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/gorilla/mux"
+)
+
+// UnlockResponse is the JSON body returned by the door unlock endpoint
+type UnlockResponse struct {
+	DoorID string                 `json:"door_id"`
+	Result hikvision.UnlockResult `json:"result"`
+}
+
+// ParseTrustedProxies parses the addresses (IPs or CIDRs) of trusted reverse proxies
+func ParseTrustedProxies(addrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(addrs))
+	for _, addr := range addrs {
+		if prefix, err := netip.ParsePrefix(addr); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", addr)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// remoteAddr returns the client address. X-Forwarded-For is only used when the
+// connection comes from a trusted proxy: then the last address not added by a
+// trusted proxy is the client.
+func remoteAddr(r *http.Request, trusted []netip.Prefix) string {
+	if !isTrusted(r.RemoteAddr, trusted) {
+		return r.RemoteAddr
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			break
+		}
+		if i == 0 || !isTrusted(hop, trusted) {
+			return hop
+		}
+	}
+	return r.RemoteAddr
+}
+
+// isTrusted reports whether an address (with or without a port) is a trusted proxy
+func isTrusted(addr string, trusted []netip.Prefix) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeUnlock checks that an unlock request carries the door token and
+// does not come from a web page of another origin. It returns the HTTP status
+// and reason of a rejection, or zero if the request is allowed.
+func (h *Handler) authorizeUnlock(r *http.Request) (int, string) {
+	// Browsers send the origin of cross-site requests, even simple ones that
+	// skip the CORS preflight, so foreign pages cannot unlock the door
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return http.StatusForbidden, "foreign origin " + origin
+		}
+	}
+
+	if h.doorToken == "" {
+		return http.StatusForbidden, "door unlock is disabled (no door_token configured)"
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.doorToken)) != 1 {
+		return http.StatusUnauthorized, "missing or invalid door token"
+	}
+	return 0, ""
+}
+
+// HandleDoorUnlock opens the door (electric strike) wired to the doorbell
+func (h *Handler) HandleDoorUnlock(w http.ResponseWriter, r *http.Request) {
+	doorID := mux.Vars(r)["id"]
+	if _, err := strconv.Atoi(doorID); err != nil {
+		http.Error(w, "Invalid door ID", http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[Door] Received unlock request for door %s", doorID)
+
+	entry := audit.Entry{
+		Device: h.name,
+		Action: "door_unlock",
+		Target: doorID,
+		Remote: remoteAddr(r, h.trustedProxies),
+	}
+
+	if status, reason := h.authorizeUnlock(r); status != 0 {
+		log.Printf("[Door] Rejected unlock request for door %s: %s", doorID, reason)
+		entry.Result = "denied"
+		entry.Error = reason
+		h.auditLog.Record(entry)
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "Door unlock not authorized", status)
+		return
+	}
+
+	result, err := h.hikClient.UnlockDoor(r.Context(), doorID)
+	if err != nil {
+		log.Printf("[Door] Failed to unlock door %s: %v", doorID, err)
+		entry.Result = "error"
+		entry.Error = err.Error()
+		h.auditLog.Record(entry)
+		http.Error(w, "Failed to unlock door", deviceErrorStatus(err))
+		return
+	}
+
+	entry.Result = string(result)
+	h.auditLog.Record(entry)
+
+	status := http.StatusOK
+	switch result {
+	case hikvision.UnlockResultLockedOut:
+		status = http.StatusLocked
+	case hikvision.UnlockResultNotSupported:
+		status = http.StatusNotImplemented
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(UnlockResponse{
+		DoorID: doorID,
+		Result: result,
+	})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/acardace/hikvision-doorbell-server/internal/session"
+)
+
+// deviceErrorStatus maps an error from the device layer to an HTTP status code
+func deviceErrorStatus(err error) int {
+	var hikErr *hikvision.Error
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, hikvision.ErrChannelBusy),
+		errors.Is(err, hikvision.ErrInvalidSession),
+		errors.Is(err, session.ErrNoAvailableChannels):
+		// The indoor station or another client holds the channel
+		return http.StatusConflict
+	case errors.Is(err, hikvision.ErrDeviceLocked):
+		return http.StatusLocked
+	case errors.Is(err, hikvision.ErrAuthLockedOut):
+		// Requests are suspended until the backoff expires or the credentials change
+		return http.StatusServiceUnavailable
+	case errors.Is(err, hikvision.ErrNotSupported),
+		errors.Is(err, session.ErrUnsupportedCodec):
+		return http.StatusNotImplemented
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &netErr):
+		// The device is unreachable, e.g. it was down at startup
+		return http.StatusServiceUnavailable
+	case errors.Is(err, hikvision.ErrUnauthorized), errors.As(err, &hikErr):
+		// The device rejected our request, which is a server-side problem for the API client
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+)
+
+// sseKeepaliveInterval is how often a comment line is sent to keep idle SSE connections open
+const sseKeepaliveInterval = 30 * time.Second
+
+// HandleEvents streams device events to the client as Server-Sent Events
+func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.eventBus.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.Log.Info("event subscriber connected",
+		slog.String("component", "events"),
+		slog.String("remote", r.RemoteAddr))
+	defer logger.Log.Info("event subscriber disconnected",
+		slog.String("component", "events"),
+		slog.String("remote", r.RemoteAddr))
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(ev)
+			if err != nil {
+				logger.Log.Error("failed to encode event",
+					slog.String("component", "events"),
+					slog.String("error", err.Error()))
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
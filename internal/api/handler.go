@@ -1,41 +1,164 @@
 package api
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"net/netip"
+	"time"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+	"github.com/acardace/hikvision-doorbell-server/internal/audit"
+	"github.com/acardace/hikvision-doorbell-server/internal/events"
 	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
 	"github.com/acardace/hikvision-doorbell-server/internal/session"
+	"github.com/acardace/hikvision-doorbell-server/internal/streaming"
+	"github.com/acardace/hikvision-doorbell-server/internal/tts"
 	"github.com/gorilla/mux"
 )
 
+// Handler serves the API for a single device
 type Handler struct {
-	hikClient     *hikvision.Client
-	webrtcHandler *WebRTCHandler
-	abortManager  *AbortManager
+	name           string
+	hikClient      *hikvision.Client
+	sessionManager session.SessionManager
+	webrtcHandler  *WebRTCHandler
+	abortManager   *AbortManager
+	eventBus       *events.Bus
+	snapshotCache  *SnapshotCache
+	auditLog       *audit.Log
+	processing     audio.Processing
+	tts            *tts.Cache
+	doorToken      string
+	trustedProxies []netip.Prefix
 }
 
-func NewHandler(hikClient *hikvision.Client) *Handler {
+// HandlerConfig holds the settings for a device Handler
+type HandlerConfig struct {
+	// Name identifies the device in routes, events and audit entries
+	Name string
+
+	// Codec is the two-way audio codec to switch the device to,
+	// or empty to use whatever the device is configured with
+	Codec string
+
+	// WebRTC is the WebRTC configuration, shared between devices
+	WebRTC *WebRTCConfig
+
+	// EventBus carries the events of this device
+	EventBus *events.Bus
+
+	// AuditLog records security-relevant actions, shared between devices
+	AuditLog *audit.Log
+
+	// AutoAnswer answers a ringing doorbell call when a WebRTC session starts
+	// and hangs it up when the session ends
+	AutoAnswer bool
+
+	// Video is the device RTSP stream sent to WebRTC clients that offer video,
+	// or empty for audio only
+	Video hikvision.VideoStream
+
+	// RTSPPort is the RTSP port of the device, or zero for the default
+	RTSPPort int
+
+	// Processing is the default processing of audio played on the doorbell
+	// speaker, which requests can override
+	Processing audio.Processing
+
+	// Echo configures echo suppression of WebRTC sessions
+	Echo streaming.EchoSuppression
+
+	// TTS synthesizes the announcements of the say endpoint, shared between
+	// devices, or nil if text-to-speech is not configured
+	TTS *tts.Cache
+
+	// DoorToken must be sent as a Bearer token to unlock a door.
+	// Door unlock is disabled while it is empty.
+	DoorToken string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For header
+	// gives the client address recorded in the audit log
+	TrustedProxies []netip.Prefix
+}
+
+// NewHandler creates the API handler for a device
+func NewHandler(hikClient *hikvision.Client, cfg HandlerConfig) *Handler {
 	// Create session manager and abort manager
-	sessionManager := session.NewHikvisionSessionManager(hikClient)
+	sessionManager := session.NewHikvisionSessionManager(hikClient, cfg.Codec)
 	abortManager := NewAbortManager(sessionManager)
 
-	return &Handler{
-		hikClient:     hikClient,
-		webrtcHandler: NewWebRTCHandler(hikClient, sessionManager, abortManager),
-		abortManager:  abortManager,
+	var videoStreamer streaming.VideoStreamer
+	if cfg.Video != "" {
+		videoStreamer = streaming.NewHikvisionVideoStreamer(hikClient, cfg.Video, cfg.RTSPPort)
+	}
+
+	h := &Handler{
+		name:           cfg.Name,
+		hikClient:      hikClient,
+		sessionManager: sessionManager,
+		abortManager:   abortManager,
+		eventBus:       cfg.EventBus,
+		snapshotCache:  NewSnapshotCache(hikClient, snapshotCacheTTL),
+		auditLog:       cfg.AuditLog,
+		processing:     cfg.Processing,
+		tts:            cfg.TTS,
+		doorToken:      cfg.DoorToken,
+		trustedProxies: cfg.TrustedProxies,
 	}
+	h.webrtcHandler = NewWebRTCHandler(cfg.WebRTC, hikClient, sessionManager, abortManager, videoStreamer, cfg.AutoAnswer, cfg.Processing, cfg.Echo, h.publishReconnect)
+	return h
+}
+
+// publishReconnect tells event subscribers that a device audio stream dropped and is reconnecting
+func (h *Handler) publishReconnect(ev streaming.ReconnectEvent) {
+	if h.eventBus == nil {
+		return
+	}
+	h.eventBus.Publish(events.Event{
+		Device:      h.name,
+		Type:        "audio_stream",
+		EventType:   ev.Stream,
+		State:       ev.State,
+		ChannelID:   ev.ChannelID,
+		Description: ev.Error,
+		Time:        time.Now(),
+	})
+}
+
+// Name returns the device name
+func (h *Handler) Name() string {
+	return h.name
+}
+
+// checkHealth tests the connection to the device (quietly, without logging)
+func (h *Handler) checkHealth(ctx context.Context) error {
+	_, err := h.hikClient.GetTwoWayAudioChannelsQuiet(ctx)
+	return err
+}
+
+// healthAuthLockedOut is the health state reported while requests are suspended after auth failures
+const healthAuthLockedOut = "auth locked out"
+
+// logHealthError logs a failed health check and returns the state to report
+func (h *Handler) logHealthError(err error) string {
+	if status := h.hikClient.AuthStatus(); status.LockedOut {
+		log.Printf("[Health] Device %s auth locked out after %d failures, retrying at %s",
+			h.name, status.Failures, status.RetryAt.Format(time.TimeOnly))
+		return healthAuthLockedOut
+	}
+
+	log.Printf("[Health] Device %s unreachable: %v", h.name, err)
+	return "unhealthy"
 }
 
 // Healthz endpoint for Kubernetes health probes
 func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
-	// Test connection to doorbell by getting channels (quietly, without logging)
-	_, err := h.hikClient.GetTwoWayAudioChannelsQuiet()
-	if err != nil {
+	if err := h.checkHealth(r.Context()); err != nil {
 		// Only log errors, not successful health checks
-		log.Printf("[Health] Device unreachable: %v", err)
+		state := h.logHealthError(err)
 		w.WriteHeader(http.StatusServiceUnavailable)
-		w.Write([]byte("unhealthy"))
+		w.Write([]byte(state))
 		return
 	}
 
@@ -45,9 +168,9 @@ func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
 
 // CloseAllSessions closes all active audio sessions
 func (h *Handler) CloseAllSessions() error {
-	log.Println("Closing all active sessions...")
+	log.Printf("Closing all active sessions for device %s...", h.name)
 	h.webrtcHandler.Close()
-	log.Println("All sessions closed successfully")
+	log.Printf("All sessions closed successfully for device %s", h.name)
 	return nil
 }
 
@@ -57,8 +180,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		// Allow all origins for local network deployment
 		// In production, you might want to restrict this to specific origins
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -70,24 +193,95 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// SetupRoutes configures all API routes
-func (h *Handler) SetupRoutes() *mux.Router {
-	router := mux.NewRouter()
+// RegisterRoutes adds the device API routes to a router.
+// Paths are relative, so the same routes can be mounted under /api and /api/devices/{name}.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	// Device health
+	router.HandleFunc("/healthz", h.Healthz).Methods("GET")
 
-	// Apply CORS middleware
-	router.Use(corsMiddleware)
+	// Device information and capabilities
+	router.HandleFunc("/device", h.HandleDevice).Methods("GET")
 
-	// Health check
-	router.HandleFunc("/healthz", h.Healthz).Methods("GET")
+	// Device events (Server-Sent Events)
+	router.HandleFunc("/events", h.HandleEvents).Methods("GET")
+
+	// Camera snapshot
+	router.HandleFunc("/snapshot", h.HandleSnapshot).Methods("GET")
+
+	// Recorded clips on the device SD card
+	router.HandleFunc("/recordings", h.HandleRecordings).Methods("GET")
+	router.HandleFunc("/recordings/download", h.HandleRecordingDownload).Methods("GET")
+
+	// Door control
+	router.HandleFunc("/door/{id}/unlock", h.HandleDoorUnlock).Methods("POST", "OPTIONS")
+
+	// Video intercom call control
+	router.HandleFunc("/call", h.HandleCallStatus).Methods("GET")
+	router.HandleFunc("/call/{action}", h.HandleCallAction).Methods("POST", "OPTIONS")
 
 	// WebRTC signaling
-	router.HandleFunc("/api/webrtc/offer", h.webrtcHandler.HandleOffer).Methods("POST", "OPTIONS")
+	router.HandleFunc("/webrtc/offer", h.webrtcHandler.HandleOffer).Methods("POST", "OPTIONS")
+	router.HandleFunc("/webrtc/session", h.webrtcHandler.HandleSessionStats).Methods("GET")
+
+	// Speaker and microphone volume
+	router.HandleFunc("/audio/volume", h.HandleGetVolume).Methods("GET")
+	router.HandleFunc("/audio/volume", h.HandleSetVolume).Methods("PUT", "OPTIONS")
 
 	// Play audio file (with automatic session management)
-	router.HandleFunc("/api/audio/play-file", HandlePlayFile(h.hikClient, h.abortManager)).Methods("POST", "OPTIONS")
+	router.HandleFunc("/audio/play-file", HandlePlayFile(h.hikClient, h.sessionManager, h.abortManager, h.processing)).Methods("POST", "OPTIONS")
+
+	// Speak a text (text-to-speech, played like a file)
+	router.HandleFunc("/audio/say", HandleSay(h.hikClient, h.sessionManager, h.abortManager, h.tts, h.processing)).Methods("POST", "OPTIONS")
 
 	// Abort all operations
-	router.HandleFunc("/api/abort", h.HandleAbort).Methods("POST", "OPTIONS")
+	router.HandleFunc("/abort", h.HandleAbort).Methods("POST", "OPTIONS")
+}
+
+// healthzAll reports healthy only if every device is reachable.
+// An auth lockout on any device takes precedence in the reported state.
+func healthzAll(handlers []*Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state := ""
+		for _, h := range handlers {
+			if err := h.checkHealth(r.Context()); err != nil {
+				if s := h.logHealthError(err); state == "" || s == healthAuthLockedOut {
+					state = s
+				}
+			}
+		}
+
+		if state != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(state))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("healthy"))
+	}
+}
+
+// SetupRoutes configures the API routes for all devices.
+// Each device is served under /api/devices/{name}, and the default device
+// is also served under /api for compatibility with single-device clients.
+func SetupRoutes(handlers []*Handler, defaultDevice string) *mux.Router {
+	router := mux.NewRouter()
+
+	// Apply CORS middleware
+	router.Use(corsMiddleware)
+
+	// Health check
+	router.HandleFunc("/healthz", healthzAll(handlers)).Methods("GET")
+
+	for _, h := range handlers {
+		h.RegisterRoutes(router.PathPrefix("/api/devices/" + h.name).Subrouter())
+	}
+
+	for _, h := range handlers {
+		if h.name == defaultDevice {
+			h.RegisterRoutes(router.PathPrefix("/api").Subrouter())
+		}
+	}
 
 	return router
 }
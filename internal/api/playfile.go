@@ -8,13 +8,15 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
 	"github.com/acardace/hikvision-doorbell-server/internal/session"
 )
 
 // HandlePlayFile handles uploading and playing an audio file
 // This automatically manages the session lifecycle
-func HandlePlayFile(hikClient *hikvision.Client, abortManager *AbortManager) http.HandlerFunc {
+// processing is the default processing of the audio, which the request can override.
+func HandlePlayFile(hikClient *hikvision.Client, sessionManager session.SessionManager, abortManager *AbortManager, processing audio.Processing) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if there's an active op
 		if abortManager.HasActiveOperation() {
@@ -44,6 +46,13 @@ func HandlePlayFile(hikClient *hikvision.Client, abortManager *AbortManager) htt
 			return
 		}
 
+		processing, err := processingOptions(r.FormValue, processing)
+		if err != nil {
+			log.Printf("[PlayFile] Invalid audio processing: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		file, _, err := r.FormFile("audio")
 		if err != nil {
 			log.Printf("[PlayFile] Failed to get file from form: %v", err)
@@ -62,74 +71,158 @@ func HandlePlayFile(hikClient *hikvision.Client, abortManager *AbortManager) htt
 
 		log.Printf("[PlayFile] Read %d bytes of audio data", len(audioData))
 
-		sessionManager := session.NewHikvisionSessionManager(hikClient)
+		// Raw uploads are G.711 µ-law, files in other formats are decoded first
+		format := audio.SniffFormat(audioData)
+		var samples []int16
+		if format != audio.FileFormatRaw {
+			samples, err = audio.DecodeFile(format, audioData)
+			if err != nil {
+				log.Printf("[PlayFile] Failed to decode audio: %v", err)
+				http.Error(w, fmt.Sprintf("Failed to decode audio: %v", err), http.StatusUnsupportedMediaType)
+				return
+			}
+			log.Printf("[PlayFile] Decoded %s file to %.2f seconds of audio", format, float64(len(samples))/audio.SampleRate)
+		}
+
+		playAudio(ctx, w, hikClient, sessionManager, "PlayFile", format, audioData, samples, processing)
+	}
+}
+
+// playAudio plays a clip on the device speaker, opening and closing an audio
+// channel around it, and writes the HTTP response. Raw clips are G.711 µ-law
+// audioData; others are decoded to samples. tag prefixes the log messages.
+func playAudio(ctx context.Context, w http.ResponseWriter, hikClient *hikvision.Client, sessionManager session.SessionManager, tag string, format audio.FileFormat, audioData []byte, samples []int16, processing audio.Processing) {
+	session, err := sessionManager.AcquireChannel(ctx)
+	if err != nil {
+		log.Printf("[%s] Failed to open audio channel: %v", tag, err)
+		http.Error(w, fmt.Sprintf("Failed to open audio channel: %v", err), deviceErrorStatus(err))
+		return
+	}
 
-		session, err := sessionManager.AcquireChannel(ctx)
+	// Ensure we close the channel when done
+	defer func() {
+		log.Printf("[%s] Closing audio channel...", tag)
+		// Use Background context for cleanup to ensure it completes even if operation was cancelled
+		sessionManager.ReleaseChannel(context.Background(), session.ChannelID)
+	}()
+
+	// Encode to the channel codec
+	deviceCodec, ok := audio.CodecByName(session.Codec)
+	if !ok {
+		deviceCodec = audio.PCMU
+	}
+	if format != audio.FileFormatRaw {
+		audioData, err = audio.Encode(deviceCodec, samples)
+	} else {
+		audioData, err = audio.Transcode(audio.PCMU, deviceCodec, audioData)
+	}
+	if err != nil {
+		log.Printf("[%s] Cannot play on channel codec %s: %v", tag, session.Codec, err)
+		http.Error(w, fmt.Sprintf("Device codec %s is not supported for file playback", session.Codec), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Normalize the whole clip to one loudness, rather than follow its level as it plays
+	var proc *audio.Processor
+	if processing.Enabled() {
+		clip, err := audio.Decode(deviceCodec, audioData)
 		if err != nil {
-			log.Printf("[PlayFile] Failed to open audio channel: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to open audio channel: %v", err), http.StatusInternalServerError)
+			log.Printf("[%s] Cannot process audio on channel codec %s: %v", tag, session.Codec, err)
+			http.Error(w, fmt.Sprintf("Audio processing is not supported with device codec %s", session.Codec), http.StatusUnsupportedMediaType)
 			return
 		}
+		proc = audio.NewProcessor(processing)
+		proc.Measure(clip)
+		log.Printf("[%s] Processing audio (normalize %t, gain %g dB, limiter %t)", tag,
+			processing.Normalize, processing.Gain, processing.Limiter)
+	}
 
-		// Ensure we close the channel when done
-		defer func() {
-			log.Println("[PlayFile] Closing audio channel...")
-			// Use Background context for cleanup to ensure it completes even if operation was cancelled
-			sessionManager.ReleaseChannel(context.Background(), session.ChannelID)
-		}()
+	// Create audio writer
+	hikvisionSession := hikvision.AudioSession{
+		ChannelID: session.ChannelID,
+		SessionID: session.SessionID,
+	}
 
-		// Create audio writer
-		hikvisionSession := hikvision.AudioSession{
-			ChannelID: session.ChannelID,
-			SessionID: session.SessionID,
+	writer := hikClient.NewAudioStreamWriter(&hikvisionSession)
+	writer.SetCodec(deviceCodec)
+	writer.SetReconnect(hikvision.ReconnectPolicy{
+		Reopen: func(ctx context.Context) (*hikvision.AudioSession, error) {
+			reopened, err := sessionManager.ReopenChannel(ctx, session)
+			if err != nil {
+				return nil, err
+			}
+			session = reopened
+			return &hikvision.AudioSession{
+				ChannelID: reopened.ChannelID,
+				SessionID: reopened.SessionID,
+			}, nil
+		},
+		OnEvent: func(ev hikvision.ReconnectEvent) {
+			if ev.Err != nil {
+				log.Printf("[%s] Audio stream %s (attempt %d): %v", tag, ev.State, ev.Attempt, ev.Err)
+				return
+			}
+			log.Printf("[%s] Audio stream %s (attempt %d)", tag, ev.State, ev.Attempt)
+		},
+	})
+	writer.Start(ctx)
+	defer writer.Close()
+
+	// Process the audio on its way to the device
+	var out io.Writer = writer
+	if proc != nil {
+		out, err = audio.NewProcessedWriter(writer, deviceCodec, proc)
+		if err != nil {
+			log.Printf("[%s] Failed to set up audio processing: %v", tag, err)
+			http.Error(w, "Failed to process audio", http.StatusInternalServerError)
+			return
 		}
+	}
 
-		writer := hikClient.NewAudioStreamWriter(&hikvisionSession)
-		writer.Start()
-		defer writer.Close()
+	// Send audio data in chunks
+	chunkSize := 4096
+	totalChunks := (len(audioData) + chunkSize - 1) / chunkSize
+	log.Printf("[%s] Sending %d chunks...", tag, totalChunks)
 
-		// Send audio data in chunks
-		chunkSize := 4096
-		totalChunks := (len(audioData) + chunkSize - 1) / chunkSize
-		log.Printf("[PlayFile] Sending %d chunks...", totalChunks)
+	for i := 0; i < len(audioData); i += chunkSize {
+		select {
+		case <-ctx.Done():
+			http.Error(w, "Operation interrupted", http.StatusServiceUnavailable)
+			return
+		default:
+			end := i + chunkSize
+			if end > len(audioData) {
+				end = len(audioData)
+			}
 
-		for i := 0; i < len(audioData); i += chunkSize {
-			select {
-			case <-ctx.Done():
-				http.Error(w, "Operation interrupted", http.StatusServiceUnavailable)
+			chunk := audioData[i:end]
+			_, err := out.Write(chunk)
+			if err != nil {
+				log.Printf("[%s] Failed to write chunk: %v", tag, err)
+				http.Error(w, "Failed to send audio", deviceErrorStatus(err))
 				return
-			default:
-				end := i + chunkSize
-				if end > len(audioData) {
-					end = len(audioData)
-				}
-
-				chunk := audioData[i:end]
-				_, err := writer.Write(chunk)
-				if err != nil {
-					log.Printf("[PlayFile] Failed to write chunk: %v", err)
-					http.Error(w, "Failed to send audio", http.StatusInternalServerError)
-					return
-				}
 			}
 		}
+	}
 
-		log.Println("[PlayFile] All audio data sent")
+	log.Printf("[%s] All audio data sent", tag)
 
-		// Calculate playback duration and wait for audio to finish
-		// G.711 is 8000 bytes/sec
-		audioDuration := time.Duration(len(audioData)) * time.Second / 8000
-		log.Printf("[PlayFile] Waiting %.2f seconds for playback to complete...", audioDuration.Seconds())
+	// Wait for the writer to send the queued audio at playback speed
+	audioDuration := time.Duration(len(audioData)) * time.Second / 8000
+	log.Printf("[%s] Waiting for %.2f seconds of audio to play...", tag, audioDuration.Seconds())
 
-		select {
-		case <-ctx.Done():
+	if err := writer.Flush(ctx); err != nil {
+		if ctx.Err() != nil {
 			http.Error(w, "Operation interrupted", http.StatusServiceUnavailable)
 			return
-		case <-time.After(audioDuration):
-			log.Println("[PlayFile] Playback complete")
 		}
-
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Audio played successfully"))
+		log.Printf("[%s] Failed to send audio: %v", tag, err)
+		http.Error(w, "Failed to send audio", deviceErrorStatus(err))
+		return
 	}
+	stats := writer.Stats()
+	log.Printf("[%s] Playback complete (%d frames, %d resyncs)", tag, stats.Frames, stats.Resyncs)
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Audio played successfully"))
 }
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+)
+
+// processingOptions overrides the default audio processing with the settings
+// of a request: normalize, target_loudness, gain, limiter and limiter_ceiling.
+// get returns a request parameter, empty if it is not set.
+func processingOptions(get func(string) string, defaults audio.Processing) (audio.Processing, error) {
+	p := defaults
+
+	bools := map[string]*bool{
+		"normalize": &p.Normalize,
+		"limiter":   &p.Limiter,
+	}
+	for name, v := range bools {
+		if s := get(name); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s %q", name, s)
+			}
+			*v = b
+		}
+	}
+
+	floats := map[string]*float64{
+		"target_loudness": &p.TargetLoudness,
+		"gain":            &p.Gain,
+		"limiter_ceiling": &p.LimiterCeiling,
+	}
+	for name, v := range floats {
+		if s := get(name); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return p, fmt.Errorf("invalid %s %q", name, s)
+			}
+			*v = f
+		}
+	}
+
+	return p, p.Validate()
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// defaultRecordingsWindow is the time range searched when "from" is not given
+const defaultRecordingsWindow = 24 * time.Hour
+
+// RecordingItem is a recording in the list returned by the recordings endpoint
+type RecordingItem struct {
+	hikvision.Recording
+	Download string `json:"download"` // Path of the download route for this recording
+}
+
+// RecordingsResponse is the JSON body returned by the recordings endpoint
+type RecordingsResponse struct {
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	Recordings []RecordingItem `json:"recordings"`
+}
+
+// parseRecordingsQuery reads the search from the from, to, type and track query parameters
+func parseRecordingsQuery(r *http.Request) (hikvision.RecordingSearch, error) {
+	query := r.URL.Query()
+	search := hikvision.RecordingSearch{
+		TrackID: query.Get("track"),
+		To:      time.Now(),
+		Type:    hikvision.RecordingTypeAll,
+	}
+
+	if track := search.TrackID; track != "" {
+		if _, err := strconv.Atoi(track); err != nil {
+			return search, fmt.Errorf("invalid track")
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return search, fmt.Errorf("invalid to time, use RFC 3339 (e.g. 2025-01-01T10:00:00Z)")
+		}
+		search.To = t
+	}
+
+	search.From = search.To.Add(-defaultRecordingsWindow)
+	if from := query.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return search, fmt.Errorf("invalid from time, use RFC 3339 (e.g. 2025-01-01T10:00:00Z)")
+		}
+		search.From = t
+	}
+
+	if !search.From.Before(search.To) {
+		return search, fmt.Errorf("from must be before to")
+	}
+
+	if name := query.Get("type"); name != "" {
+		t, ok := hikvision.ParseRecordingType(name)
+		if !ok {
+			return search, fmt.Errorf("invalid type, use all, continuous, motion, alarm or event")
+		}
+		search.Type = t
+	}
+
+	return search, nil
+}
+
+// HandleRecordings lists the clips stored on the doorbell SD card
+func (h *Handler) HandleRecordings(w http.ResponseWriter, r *http.Request) {
+	search, err := parseRecordingsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recordings, err := h.hikClient.SearchRecordings(r.Context(), search)
+	if err != nil {
+		log.Printf("[Recordings] Failed to search recordings: %v", err)
+		http.Error(w, "Failed to search recordings", deviceErrorStatus(err))
+		return
+	}
+
+	resp := RecordingsResponse{
+		From:       search.From,
+		To:         search.To,
+		Recordings: make([]RecordingItem, 0, len(recordings)),
+	}
+	for _, rec := range recordings {
+		resp.Recordings = append(resp.Recordings, RecordingItem{
+			Recording: rec,
+			Download:  r.URL.Path + "/download?uri=" + url.QueryEscape(rec.PlaybackURI),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleRecordingDownload streams a recording from the doorbell to the client
+func (h *Handler) HandleRecordingDownload(w http.ResponseWriter, r *http.Request) {
+	playbackURI := r.URL.Query().Get("uri")
+	u, err := url.Parse(playbackURI)
+	if err != nil || u.Scheme != "rtsp" {
+		http.Error(w, "Invalid uri, use the playback_uri of a recording", http.StatusBadRequest)
+		return
+	}
+
+	download, err := h.hikClient.DownloadRecording(r.Context(), playbackURI)
+	if err != nil {
+		log.Printf("[Recordings] Failed to download recording: %v", err)
+		http.Error(w, "Failed to download recording", deviceErrorStatus(err))
+		return
+	}
+	defer download.Body.Close()
+
+	// Playback URIs carry the file name on the device
+	name := u.Query().Get("name")
+	if name == "" {
+		name = "recording"
+	}
+
+	w.Header().Set("Content-Type", download.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".mp4"))
+	if download.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(download.ContentLength, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	n, err := io.Copy(w, download.Body)
+	if err != nil {
+		log.Printf("[Recordings] Download of %s interrupted after %d bytes: %v", name, n, err)
+		return
+	}
+	log.Printf("[Recordings] Downloaded %s (%d bytes)", name, n)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/acardace/hikvision-doorbell-server/internal/session"
+	"github.com/acardace/hikvision-doorbell-server/internal/tts"
+)
+
+// maxSayLength is the longest text accepted for an announcement, in characters
+const maxSayLength = 1000
+
+// maxSayBodySize bounds the JSON body of the say endpoint
+const maxSayBodySize = 64 << 10 // 64 KB
+
+// SayRequest is the JSON body of the say endpoint
+type SayRequest struct {
+	Text     string `json:"text"`
+	Voice    string `json:"voice,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// HandleSay speaks a text on the doorbell speaker, synthesized by provider.
+// It is played like an uploaded file, and processing is overridden by the query.
+func HandleSay(hikClient *hikvision.Client, sessionManager session.SessionManager, abortManager *AbortManager, provider *tts.Cache, processing audio.Processing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			http.Error(w, "Text-to-speech is not configured", http.StatusNotImplemented)
+			return
+		}
+
+		// Check if there's an active op
+		if abortManager.HasActiveOperation() {
+			log.Println("[Say] Rejected: another session is active")
+			http.Error(w, "Cannot speak while another session is active", http.StatusConflict)
+			return
+		}
+
+		// Create a cancellable context for this operation
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// Register as a file playback, so WebRTC sessions take precedence
+		op := abortManager.Register(OperationTypePlayFile, cancel)
+		defer func() {
+			abortManager.Unregister(op)
+			op.Cleanup.Done() // Signal cleanup completion
+		}()
+
+		var req SayRequest
+		r.Body = http.MaxBytesReader(w, r.Body, maxSayBodySize)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Request body larger than %d bytes", maxSayBodySize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Text == "" {
+			http.Error(w, "No text provided", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(req.Text) > maxSayLength {
+			http.Error(w, fmt.Sprintf("Text longer than %d characters", maxSayLength), http.StatusBadRequest)
+			return
+		}
+
+		processing, err := processingOptions(r.URL.Query().Get, processing)
+		if err != nil {
+			log.Printf("[Say] Invalid audio processing: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		start := time.Now()
+		speech, cached, err := provider.Lookup(ctx, tts.Request{
+			Text:     req.Text,
+			Voice:    req.Voice,
+			Language: req.Language,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				http.Error(w, "Operation interrupted", http.StatusServiceUnavailable)
+				return
+			}
+			if errors.Is(err, tts.ErrInvalidRequest) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Printf("[Say] Failed to synthesize speech: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to synthesize speech: %v", err), http.StatusBadGateway)
+			return
+		}
+		if cached {
+			log.Printf("[Say] Using cached speech for %d characters of text", utf8.RuneCountInString(req.Text))
+		} else {
+			log.Printf("[Say] Synthesized %d characters of text in %s", utf8.RuneCountInString(req.Text), time.Since(start).Round(time.Millisecond))
+		}
+
+		// Providers return audio files; raw audio would be ambiguous
+		format := audio.SniffFormat(speech)
+		if format == audio.FileFormatRaw {
+			log.Println("[Say] Speech is not in a supported audio format")
+			http.Error(w, "Text-to-speech returned audio in an unsupported format", http.StatusBadGateway)
+			return
+		}
+		samples, err := audio.DecodeFile(format, speech)
+		if err != nil {
+			log.Printf("[Say] Failed to decode speech: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to decode speech: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		playAudio(ctx, w, hikClient, sessionManager, "Say", format, speech, samples, processing)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// snapshotCacheTTL is how long a fetched snapshot is served before hitting the device again
+const snapshotCacheTTL = 2 * time.Second
+
+// maxSnapshotEntries bounds the channels cached at once, as channel IDs come from clients
+const maxSnapshotEntries = 8
+
+// snapshotEntry holds the cached snapshot for a single channel
+type snapshotEntry struct {
+	mu        sync.Mutex // Serializes fetches so concurrent requests share one device call
+	snapshot  *hikvision.Snapshot
+	fetchedAt time.Time
+}
+
+// SnapshotCache caches snapshots per channel for a short time
+type SnapshotCache struct {
+	hikClient *hikvision.Client
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*snapshotEntry
+}
+
+// NewSnapshotCache creates a new snapshot cache
+func NewSnapshotCache(hikClient *hikvision.Client, ttl time.Duration) *SnapshotCache {
+	return &SnapshotCache{
+		hikClient: hikClient,
+		ttl:       ttl,
+		entries:   make(map[string]*snapshotEntry),
+	}
+}
+
+// Get returns a cached snapshot for the channel, fetching a new one if it is stale
+func (c *SnapshotCache) Get(ctx context.Context, channelID string) (*hikvision.Snapshot, error) {
+	entry := c.entry(channelID)
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.snapshot != nil && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.snapshot, nil
+	}
+
+	snapshot, err := c.hikClient.GetSnapshot(ctx, channelID)
+	if err != nil {
+		// Do not keep entries for channels the device rejects
+		c.mu.Lock()
+		if c.entries[channelID] == entry && entry.snapshot == nil {
+			delete(c.entries, channelID)
+		}
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	entry.snapshot = snapshot
+	entry.fetchedAt = time.Now()
+	return snapshot, nil
+}
+
+// entry returns the cache entry of a channel. When the cache is full, expired
+// entries are dropped first; if it is still full, the channel gets an entry of
+// its own that is not kept.
+func (c *SnapshotCache) entry(channelID string) *snapshotEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[channelID]; ok {
+		return entry
+	}
+
+	if len(c.entries) >= maxSnapshotEntries {
+		for id, entry := range c.entries {
+			// Entries being fetched are in use, leave them
+			if !entry.mu.TryLock() {
+				continue
+			}
+			expired := entry.snapshot == nil || time.Since(entry.fetchedAt) >= c.ttl
+			entry.mu.Unlock()
+			if expired {
+				delete(c.entries, id)
+			}
+		}
+		if len(c.entries) >= maxSnapshotEntries {
+			return &snapshotEntry{}
+		}
+	}
+
+	entry := &snapshotEntry{}
+	c.entries[channelID] = entry
+	return entry
+}
+
+// HandleSnapshot returns a JPEG still from the doorbell camera
+func (h *Handler) HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	channelID := r.URL.Query().Get("channel")
+	if channelID == "" {
+		channelID = hikvision.DefaultSnapshotChannel
+	}
+	if _, err := strconv.Atoi(channelID); err != nil {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := h.snapshotCache.Get(r.Context(), channelID)
+	if err != nil {
+		log.Printf("[Snapshot] Failed to get snapshot for channel %s: %v", channelID, err)
+		http.Error(w, "Failed to get snapshot", deviceErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", snapshot.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(snapshot.Data)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(snapshot.Data)
+}
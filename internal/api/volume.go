@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+)
+
+// maxVolume is the highest volume accepted by the device
+const maxVolume = 100
+
+// VolumeSettings is the JSON body of the volume endpoint.
+// On PUT, only the fields that are set are changed.
+type VolumeSettings struct {
+	Channel          string `json:"channel"`
+	SpeakerVolume    *int   `json:"speaker_volume,omitempty"`
+	TalkVolume       *int   `json:"talk_volume,omitempty"`
+	MicrophoneVolume *int   `json:"microphone_volume,omitempty"`
+	NoiseReduction   *bool  `json:"noise_reduction,omitempty"`
+}
+
+// validate checks that the requested volumes are in range
+func (s *VolumeSettings) validate() error {
+	for name, v := range map[string]*int{
+		"speaker_volume":    s.SpeakerVolume,
+		"talk_volume":       s.TalkVolume,
+		"microphone_volume": s.MicrophoneVolume,
+	} {
+		if v != nil && (*v < 0 || *v > maxVolume) {
+			return fmt.Errorf("%s must be between 0 and %d", name, maxVolume)
+		}
+	}
+	return nil
+}
+
+// volumeChannel returns the audio channel from the query, defaulting to the first one
+func volumeChannel(r *http.Request) (string, bool) {
+	channelID := r.URL.Query().Get("channel")
+	if channelID == "" {
+		return hikvision.DefaultAudioChannel, true
+	}
+	_, err := strconv.Atoi(channelID)
+	return channelID, err == nil
+}
+
+// HandleGetVolume returns the speaker and microphone volume settings
+func (h *Handler) HandleGetVolume(w http.ResponseWriter, r *http.Request) {
+	channelID, ok := volumeChannel(r)
+	if !ok {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.readVolume(r, channelID)
+	if err != nil {
+		log.Printf("[Volume] Failed to read volume for channel %s: %v", channelID, err)
+		http.Error(w, "Failed to read volume", deviceErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// HandleSetVolume changes the speaker and microphone volume settings
+func (h *Handler) HandleSetVolume(w http.ResponseWriter, r *http.Request) {
+	channelID, ok := volumeChannel(r)
+	if !ok {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	var req VolumeSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Read-modify-write so settings we do not expose are kept
+	if req.SpeakerVolume != nil || req.TalkVolume != nil {
+		out, err := h.hikClient.GetAudioOutput(ctx, channelID)
+		if err == nil {
+			if req.SpeakerVolume != nil {
+				out.SetVolume(*req.SpeakerVolume)
+			}
+			if req.TalkVolume != nil {
+				out.SetTalkVolume(*req.TalkVolume)
+			}
+			err = h.hikClient.SetAudioOutput(ctx, channelID, out)
+		}
+		if err != nil {
+			log.Printf("[Volume] Failed to set speaker volume for channel %s: %v", channelID, err)
+			http.Error(w, "Failed to set speaker volume", deviceErrorStatus(err))
+			return
+		}
+	}
+
+	if req.MicrophoneVolume != nil || req.NoiseReduction != nil {
+		in, err := h.hikClient.GetAudioInput(ctx, channelID)
+		if err == nil {
+			if req.MicrophoneVolume != nil {
+				in.SetVolume(*req.MicrophoneVolume)
+			}
+			if req.NoiseReduction != nil {
+				in.NoiseReduce = strconv.FormatBool(*req.NoiseReduction)
+			}
+			err = h.hikClient.SetAudioInput(ctx, channelID, in)
+		}
+		if err != nil {
+			log.Printf("[Volume] Failed to set microphone settings for channel %s: %v", channelID, err)
+			http.Error(w, "Failed to set microphone settings", deviceErrorStatus(err))
+			return
+		}
+	}
+
+	settings, err := h.readVolume(r, channelID)
+	if err != nil {
+		log.Printf("[Volume] Failed to read volume for channel %s: %v", channelID, err)
+		http.Error(w, "Failed to read volume", deviceErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// readVolume collects the current speaker and microphone settings of a channel
+func (h *Handler) readVolume(r *http.Request, channelID string) (*VolumeSettings, error) {
+	out, err := h.hikClient.GetAudioOutput(r.Context(), channelID)
+	if err != nil {
+		return nil, err
+	}
+	in, err := h.hikClient.GetAudioInput(r.Context(), channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	speaker := out.Volume()
+	microphone := in.Volume()
+	settings := &VolumeSettings{
+		Channel:          channelID,
+		SpeakerVolume:    &speaker,
+		MicrophoneVolume: &microphone,
+	}
+	if talk, ok := out.TalkVolume(); ok {
+		settings.TalkVolume = &talk
+	}
+	if in.NoiseReduce != "" {
+		noiseReduction := in.NoiseReduce == "true"
+		settings.NoiseReduction = &noiseReduction
+	}
+	return settings, nil
+}
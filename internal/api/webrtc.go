@@ -3,8 +3,12 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 
 	"github.com/acardace/hikvision-doorbell-server/internal/audio"
@@ -15,28 +19,44 @@ import (
 	"github.com/pion/webrtc/v4"
 )
 
+// errNoCommonCodec is returned when the client and device cannot agree on an audio codec
+var errNoCommonCodec = errors.New("no common codec")
+
 type WebRTCHandler struct {
 	config         *WebRTCConfig
 	hikClient      *hikvision.Client
 	sessionManager session.SessionManager
 	audioStreamer  streaming.AudioStreamer
+	videoStreamer  streaming.VideoStreamer // nil when video is disabled
 	abortManager   *AbortManager
 	peerConnection *webrtc.PeerConnection
 	activeSession  *session.AudioSession
-	activeOp       *Operation // Track active WebRTC operation
+	activeOp       *Operation                     // Track active WebRTC operation
+	codec          audio.Codec                    // Codec sent to the client in the active session
+	autoAnswer     bool                           // Answer a ringing call when a session starts
+	processing     audio.Processing               // Default processing of client audio sent to the device
+	sessionProc    audio.Processing               // Processing of the active session, set by the offer
+	echo           streaming.EchoSuppression      // Echo suppression of the sessions
+	onReconnect    func(streaming.ReconnectEvent) // Told when a device audio stream drops and reconnects
+	answeredCall   bool                           // The active session answered a call, hang it up on cleanup
 	mu             sync.Mutex
 	cancelFunc     context.CancelFunc // Cancel function for goroutines
 }
 
-func NewWebRTCHandler(hikClient *hikvision.Client, sessionManager session.SessionManager, abortManager *AbortManager) *WebRTCHandler {
-	config := NewWebRTCConfig()
-	config.LoadFromEnv()
-
+// NewWebRTCHandler creates the WebRTC handler of a device.
+// videoStreamer may be nil to offer audio only, onReconnect may be nil.
+// processing is the default processing of client audio, which offers can override.
+func NewWebRTCHandler(config *WebRTCConfig, hikClient *hikvision.Client, sessionManager session.SessionManager, abortManager *AbortManager, videoStreamer streaming.VideoStreamer, autoAnswer bool, processing audio.Processing, echo streaming.EchoSuppression, onReconnect func(streaming.ReconnectEvent)) *WebRTCHandler {
 	return &WebRTCHandler{
 		config:         config,
 		hikClient:      hikClient,
 		sessionManager: sessionManager,
 		abortManager:   abortManager,
+		videoStreamer:  videoStreamer,
+		autoAnswer:     autoAnswer,
+		processing:     processing,
+		echo:           echo,
+		onReconnect:    onReconnect,
 	}
 }
 
@@ -61,11 +81,31 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 	// This ensures AbortPlayFileOperations won't affect this WebRTC session
 	h.activeOp = h.abortManager.Register(OperationTypeWebRTC, cancel)
 
+	// Release the operation and everything set up so far if the offer fails,
+	// or later offers would be rejected as a session already active
+	answered := false
+	defer func() {
+		if !answered {
+			h.cleanup()
+		}
+	}()
+
 	// Abort any ongoing play-file operations to free up the channel
 	// WebRTC connections take precedence
 	logger.Log.Info("aborting any active play-file operations", slog.String("component", "webrtc"))
 	h.abortManager.AbortPlayFileOperations(ctx)
 
+	// Audio processing settings are passed in the query, the body is the offer
+	processing, err := processingOptions(r.URL.Query().Get, h.processing)
+	if err != nil {
+		logger.Log.Error("invalid audio processing",
+			slog.String("component", "webrtc"),
+			slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.sessionProc = processing
+
 	// Parse SDP offer
 	var offer webrtc.SessionDescription
 	if err := json.NewDecoder(r.Body).Decode(&offer); err != nil {
@@ -80,9 +120,34 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 		slog.String("component", "webrtc"),
 		slog.String("type", offer.Type.String()))
 
+	// Pick the codecs for the client leg, preferring the one the device uses
+	codec, codecs, err := h.negotiateCodecs(ctx, offer)
+	if err != nil {
+		logger.Log.Error("failed to negotiate codec",
+			slog.String("component", "webrtc"),
+			slog.String("error", err.Error()))
+		if errors.Is(err, errNoCommonCodec) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		if errors.Is(err, session.ErrUnsupportedCodec) {
+			http.Error(w, err.Error(), deviceErrorStatus(err))
+			return
+		}
+		http.Error(w, "Failed to query audio channel", deviceErrorStatus(err))
+		return
+	}
+	h.codec = codec
+
+	// Send video only to clients that asked for it
+	video := h.videoStreamer != nil && offersVideo(offer)
+
 	// Create peer connection using configuration
-	peerConnection, err := h.config.CreatePeerConnection()
+	peerConnection, err := h.config.CreatePeerConnection(codecs, video)
 	if err != nil {
+		logger.Log.Error("failed to create peer connection",
+			slog.String("component", "webrtc"),
+			slog.String("error", err.Error()))
 		http.Error(w, "Failed to create peer connection", http.StatusInternalServerError)
 		return
 	}
@@ -91,7 +156,7 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 
 	// Create outgoing audio track for sending audio from doorbell to client
 	audioTrack, err := webrtc.NewTrackLocalStaticSample(
-		webrtc.RTPCodecCapability{MimeType: audio.CodecMimeType},
+		webrtc.RTPCodecCapability{MimeType: codec.MimeType},
 		"audio",
 		"doorbell-audio",
 	)
@@ -113,6 +178,16 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if video {
+		if err := h.addVideoTrack(ctx, peerConnection); err != nil {
+			logger.Log.Error("failed to add video track",
+				slog.String("component", "webrtc"),
+				slog.String("error", err.Error()))
+			http.Error(w, "Failed to add video track", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Handle incoming audio track (from browser/client to device)
 	peerConnection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		logger.Log.Info("received remote track",
@@ -120,6 +195,23 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 			slog.String("kind", track.Kind().String()),
 			slog.String("codec", track.Codec().MimeType))
 
+		// Only client audio goes to the device: read and discard anything else
+		// (e.g. a camera sent by the browser) so it does not take the channel
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			logger.Log.Warn("ignoring non-audio remote track",
+				slog.String("component", "webrtc"),
+				slog.String("kind", track.Kind().String()))
+			go func() {
+				buf := make([]byte, 1500)
+				for {
+					if _, _, err := track.Read(buf); err != nil {
+						return
+					}
+				}
+			}()
+			return
+		}
+
 		// Start session if not already active
 		if h.activeSession == nil {
 			logger.Log.Info("acquiring audio session", slog.String("component", "webrtc"))
@@ -135,7 +227,9 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 			h.activeSession = sess
 
 			// Create a fresh audio streamer for this session
-			h.audioStreamer = streaming.NewHikvisionAudioStreamer(h.hikClient)
+			streamer := streaming.NewHikvisionAudioStreamer(h.hikClient, h.sessionManager, h.codec, h.sessionProc, h.onReconnect)
+			streamer.SetEchoSuppression(h.echo)
+			h.audioStreamer = streamer
 
 			// Start audio streaming
 			if err := h.audioStreamer.Start(ctx, sess); err != nil {
@@ -145,13 +239,24 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			// Stop the doorbell ringing now that someone is talking to the visitor
+			if h.autoAnswer {
+				h.answerCall(ctx)
+			}
+
 			// Start goroutine to stream device audio to client
 			go func() {
-				if err := h.audioStreamer.StreamDeviceToClient(ctx, audioTrack); err != nil {
-					logger.Log.Error("device-to-client streaming error",
-						slog.String("component", "webrtc"),
-						slog.String("error", err.Error()))
+				err := h.audioStreamer.StreamDeviceToClient(ctx, audioTrack)
+				if err == nil || ctx.Err() != nil {
+					return
 				}
+				logger.Log.Error("device-to-client streaming error",
+					slog.String("component", "webrtc"),
+					slog.String("error", err.Error()))
+
+				// The device stream could not be reconnected: end the session
+				// rather than leave the client listening to silence
+				h.cleanup()
 			}()
 		}
 
@@ -241,12 +346,249 @@ func (h *WebRTCHandler) HandleOffer(w http.ResponseWriter, r *http.Request) {
 	<-gatherComplete
 
 	// Send answer back to client (now with all ICE candidates)
+	answered = true
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(peerConnection.LocalDescription())
 
 	logger.Log.Info("SDP answer sent successfully", slog.String("component", "webrtc"))
 }
 
+// SessionStatsResponse is the JSON body returned by the session stats endpoint
+type SessionStatsResponse struct {
+	ChannelID       string                  `json:"channel_id"`
+	DeviceCodec     string                  `json:"device_codec"`
+	ClientCodec     string                  `json:"client_codec"`
+	EchoSuppression EchoSuppressionResponse `json:"echo_suppression"`
+	Speaker         SpeakerStatsResponse    `json:"speaker"`
+}
+
+// SpeakerStatsResponse reports how the audio sent to the device speaker was paced
+type SpeakerStatsResponse struct {
+	Frames             int   `json:"frames"`
+	SilenceFrames      int   `json:"silence_frames"`
+	Underruns          int   `json:"underruns"`
+	Resyncs            int   `json:"resyncs"`
+	DroppedBytes       int   `json:"dropped_bytes"`
+	BufferedLatency    int64 `json:"buffered_latency_ms"`
+	MaxBufferedLatency int64 `json:"max_buffered_latency_ms"`
+}
+
+// EchoSuppressionResponse reports the state of the echo suppression of a session
+type EchoSuppressionResponse struct {
+	Enabled          bool    `json:"enabled"`
+	Active           bool    `json:"active"`
+	ClientLevel      float64 `json:"client_level_dbfs"`
+	Activations      int     `json:"activations"`
+	SuppressedFrames int     `json:"suppressed_frames"`
+}
+
+// HandleSessionStats returns the state of the active WebRTC session
+func (h *WebRTCHandler) HandleSessionStats(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	active := h.activeSession != nil && h.audioStreamer != nil
+	var stats streaming.SessionStats
+	if active {
+		stats = h.audioStreamer.Stats()
+	}
+	h.mu.Unlock()
+
+	if !active {
+		http.Error(w, "No active WebRTC session", http.StatusNotFound)
+		return
+	}
+
+	echo, speaker := stats.EchoSuppression, stats.Speaker
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SessionStatsResponse{
+		ChannelID:   stats.ChannelID,
+		DeviceCodec: stats.DeviceCodec,
+		ClientCodec: stats.ClientCodec,
+		EchoSuppression: EchoSuppressionResponse{
+			Enabled:          echo.Enabled,
+			Active:           echo.Active,
+			ClientLevel:      math.Round(echo.ClientLevel*10) / 10,
+			Activations:      echo.Activations,
+			SuppressedFrames: echo.SuppressedFrames,
+		},
+		Speaker: SpeakerStatsResponse{
+			Frames:             speaker.Frames,
+			SilenceFrames:      speaker.SilenceFrames,
+			Underruns:          speaker.Underruns,
+			Resyncs:            speaker.Resyncs,
+			DroppedBytes:       speaker.DroppedBytes,
+			BufferedLatency:    speaker.BufferedLatency.Milliseconds(),
+			MaxBufferedLatency: speaker.MaxBufferedLatency.Milliseconds(),
+		},
+	})
+}
+
+// negotiateCodecs picks the audio codecs for the client leg of the session.
+// The client is sent the device codec when it offers it, so audio passes
+// through untouched, or else the first offered codec we can transcode to.
+// Opus is only decoded: when the client offers it, it is accepted ahead of
+// the codec sent, so the client sends Opus and receives the other one.
+// It returns the codec sent and all the codecs to answer with, in order.
+func (h *WebRTCHandler) negotiateCodecs(ctx context.Context, offer webrtc.SessionDescription) (audio.Codec, []audio.Codec, error) {
+	deviceCodecName, err := h.sessionManager.ChannelCodec(ctx)
+	if err != nil {
+		return audio.Codec{}, nil, err
+	}
+	deviceCodec, ok := audio.CodecByName(deviceCodecName)
+	if !ok {
+		return audio.Codec{}, nil, fmt.Errorf("%w: unsupported device codec %q", errNoCommonCodec, deviceCodecName)
+	}
+
+	offered, err := offeredAudioCodecs(offer)
+	if err != nil {
+		return audio.Codec{}, nil, err
+	}
+
+	send, ok := pickSendCodec(deviceCodec, offered)
+	if !ok {
+		return audio.Codec{}, nil, fmt.Errorf("%w: client does not support device codec %s", errNoCommonCodec, deviceCodec.Name)
+	}
+	if send != deviceCodec {
+		logger.Log.Info("client does not offer device codec, transcoding",
+			slog.String("component", "webrtc"),
+			slog.String("device_codec", deviceCodec.Name),
+			slog.String("client_codec", send.Name))
+	}
+
+	for _, c := range offered {
+		if c == audio.Opus && audio.CanTranscode(c, deviceCodec) {
+			logger.Log.Info("accepting Opus from the client",
+				slog.String("component", "webrtc"),
+				slog.String("device_codec", deviceCodec.Name),
+				slog.String("client_codec", send.Name))
+			return send, []audio.Codec{c, send}, nil
+		}
+	}
+	return send, []audio.Codec{send}, nil
+}
+
+// pickSendCodec returns the codec to send the client: the device codec if
+// offered, or else the first offered one the device audio can be transcoded to
+func pickSendCodec(deviceCodec audio.Codec, offered []audio.Codec) (audio.Codec, bool) {
+	for _, c := range offered {
+		if c == deviceCodec {
+			return c, true
+		}
+	}
+	for _, c := range offered {
+		if audio.CanTranscode(deviceCodec, c) {
+			return c, true
+		}
+	}
+	return audio.Codec{}, false
+}
+
+// offeredAudioCodecs returns the supported audio codecs in an SDP offer, in the client's order of preference
+func offeredAudioCodecs(offer webrtc.SessionDescription) ([]audio.Codec, error) {
+	parsed, err := offer.Unmarshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SDP offer: %w", err)
+	}
+
+	var codecs []audio.Codec
+	for _, md := range parsed.MediaDescriptions {
+		if md.MediaName.Media != "audio" {
+			continue
+		}
+		for _, format := range md.MediaName.Formats {
+			pt, err := strconv.ParseUint(format, 10, 8)
+			if err != nil {
+				continue
+			}
+			sdpCodec, err := parsed.GetCodecForPayloadType(uint8(pt))
+			if err != nil {
+				continue
+			}
+			if c, ok := audio.CodecByMimeType("audio/" + sdpCodec.Name); ok {
+				codecs = append(codecs, c)
+			}
+		}
+	}
+
+	return codecs, nil
+}
+
+// offersVideo reports whether an SDP offer has a video media section
+func offersVideo(offer webrtc.SessionDescription) bool {
+	parsed, err := offer.Unmarshal()
+	if err != nil {
+		return false
+	}
+	for _, md := range parsed.MediaDescriptions {
+		if md.MediaName.Media == "video" {
+			return true
+		}
+	}
+	return false
+}
+
+// addVideoTrack adds the device video to the peer connection and starts streaming it
+func (h *WebRTCHandler) addVideoTrack(ctx context.Context, peerConnection *webrtc.PeerConnection) error {
+	videoTrack, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264},
+		"video",
+		"doorbell-video",
+	)
+	if err != nil {
+		return err
+	}
+
+	sender, err := peerConnection.AddTrack(videoTrack)
+	if err != nil {
+		return err
+	}
+
+	// Read incoming RTCP so the sender's interceptors keep working
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			if _, _, err := sender.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Video runs for the whole peer connection, independently of the audio session
+	go func() {
+		if err := h.videoStreamer.StreamToTrack(ctx, videoTrack); err != nil && ctx.Err() == nil {
+			logger.Log.Error("video streaming error",
+				slog.String("component", "webrtc"),
+				slog.String("error", err.Error()))
+		}
+	}()
+
+	logger.Log.Info("added video track", slog.String("component", "webrtc"))
+	return nil
+}
+
+// answerCall answers the doorbell call if it is ringing
+func (h *WebRTCHandler) answerCall(ctx context.Context) {
+	state, err := h.hikClient.GetCallStatus(ctx)
+	if err != nil {
+		logger.Log.Warn("failed to get call status",
+			slog.String("component", "webrtc"),
+			slog.String("error", err.Error()))
+		return
+	}
+	if state != hikvision.CallStateRinging {
+		return
+	}
+
+	if err := h.hikClient.SendCallSignal(ctx, hikvision.CallCommandAnswer); err != nil {
+		logger.Log.Warn("failed to answer call",
+			slog.String("component", "webrtc"),
+			slog.String("error", err.Error()))
+		return
+	}
+
+	logger.Log.Info("answered doorbell call", slog.String("component", "webrtc"))
+	h.answeredCall = true
+}
+
 // cleanup closes the session and cleans up resources
 func (h *WebRTCHandler) cleanup() {
 	// Cancel all goroutines first
@@ -255,6 +597,16 @@ func (h *WebRTCHandler) cleanup() {
 		h.cancelFunc = nil
 	}
 
+	// Hang up the call answered by this session
+	if h.answeredCall {
+		if err := h.hikClient.SendCallSignal(context.Background(), hikvision.CallCommandHangUp); err != nil {
+			logger.Log.Warn("failed to hang up call",
+				slog.String("component", "webrtc"),
+				slog.String("error", err.Error()))
+		}
+		h.answeredCall = false
+	}
+
 	// Stop audio streaming
 	if h.audioStreamer != nil {
 		h.audioStreamer.Stop()
@@ -2,10 +2,14 @@ package api
 
 import (
 	"log/slog"
+	"net"
 	"os"
 	"strings"
+	"sync"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+	"github.com/pion/ice/v4"
 	"github.com/pion/webrtc/v4"
 )
 
@@ -20,6 +24,11 @@ type WebRTCConfig struct {
 	// PublicIPFile is the path to a file containing the public IP
 	// (useful when IP is set by init containers in Kubernetes)
 	PublicIPFile string
+
+	// udpMux shares the single UDP port between the peer connections of all devices
+	udpMux     ice.UDPMux
+	udpMuxOnce sync.Once
+	udpMuxErr  error
 }
 
 // NewWebRTCConfig creates a new WebRTC configuration with defaults
@@ -63,8 +72,52 @@ func (c *WebRTCConfig) LoadFromEnv() error {
 	return nil
 }
 
-// CreateAPI creates a WebRTC API with the configured settings
-func (c *WebRTCConfig) CreateAPI() (*webrtc.API, error) {
+// getUDPMux opens the shared UDP port on first use
+func (c *WebRTCConfig) getUDPMux() (ice.UDPMux, error) {
+	c.udpMuxOnce.Do(func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: int(c.Port)})
+		if err != nil {
+			c.udpMuxErr = err
+			return
+		}
+		c.udpMux = webrtc.NewICEUDPMux(nil, conn)
+	})
+	return c.udpMux, c.udpMuxErr
+}
+
+// h264ProfileLevelIDs are the H.264 profiles offered for the device video, by payload type.
+// Browsers differ in the profiles they offer; the device stream is relayed as is,
+// so any of them decodes it as long as the level is high enough.
+var h264ProfileLevelIDs = []struct {
+	payloadType webrtc.PayloadType
+	profile     string
+}{
+	{102, "42001f"}, // Constrained Baseline
+	{106, "42e01f"}, // Constrained Baseline
+	{127, "4d001f"}, // Main
+	{112, "64001f"}, // High
+}
+
+// registerVideoCodecs registers H.264 in packetization mode 1 (fragmented NAL units), as sent by the device
+func registerVideoCodecs(mediaEngine *webrtc.MediaEngine) error {
+	for _, p := range h264ProfileLevelIDs {
+		if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    webrtc.MimeTypeH264,
+				ClockRate:   90000,
+				SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=" + p.profile,
+			},
+			PayloadType: p.payloadType,
+		}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateAPI creates a WebRTC API with the configured settings and the given audio codecs
+// in order of preference, and the H.264 video codec if video is enabled
+func (c *WebRTCConfig) CreateAPI(codecs []audio.Codec, video bool) (*webrtc.API, error) {
 	settingEngine := webrtc.SettingEngine{}
 
 	// Only use UDP4 (no TCP, no IPv6)
@@ -72,14 +125,16 @@ func (c *WebRTCConfig) CreateAPI() (*webrtc.API, error) {
 		webrtc.NetworkTypeUDP4,
 	})
 
-	// Use fixed UDP port (single user at a time)
-	if err := settingEngine.SetEphemeralUDPPortRange(c.Port, c.Port); err != nil {
-		logger.Log.Error("failed to set UDP port range",
+	// Use a fixed UDP port shared by all peer connections (one per device)
+	udpMux, err := c.getUDPMux()
+	if err != nil {
+		logger.Log.Error("failed to listen on UDP port",
 			slog.String("component", "webrtc_config"),
 			slog.Int("port", int(c.Port)),
 			slog.String("error", err.Error()))
 		return nil, err
 	}
+	settingEngine.SetICEUDPMux(udpMux)
 
 	// Set public IP for NAT traversal if configured
 	if c.PublicIP != "" {
@@ -89,25 +144,42 @@ func (c *WebRTCConfig) CreateAPI() (*webrtc.API, error) {
 		settingEngine.SetNAT1To1IPs([]string{c.PublicIP}, webrtc.ICECandidateTypeHost)
 	}
 
-	// Create MediaEngine with only PCMU codec
+	// Create MediaEngine with only the negotiated codecs, so the answer offers nothing else
 	mediaEngine := &webrtc.MediaEngine{}
-	if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
-		RTPCodecCapability: webrtc.RTPCodecCapability{
-			MimeType:    "audio/PCMU",
-			ClockRate:   8000,
-			Channels:    1,
-			SDPFmtpLine: "",
-		},
-		PayloadType: 0,
-	}, webrtc.RTPCodecTypeAudio); err != nil {
-		logger.Log.Error("failed to register PCMU codec",
-			slog.String("component", "webrtc_config"),
-			slog.String("error", err.Error()))
-		return nil, err
+	mimeTypes := make([]string, 0, len(codecs))
+	for _, codec := range codecs {
+		if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    codec.MimeType,
+				ClockRate:   codec.ClockRate,
+				Channels:    codec.Channels,
+				SDPFmtpLine: codec.SDPFmtpLine,
+			},
+			PayloadType: webrtc.PayloadType(codec.PayloadType),
+		}, webrtc.RTPCodecTypeAudio); err != nil {
+			logger.Log.Error("failed to register codec",
+				slog.String("component", "webrtc_config"),
+				slog.String("codec", codec.MimeType),
+				slog.String("error", err.Error()))
+			return nil, err
+		}
+		mimeTypes = append(mimeTypes, codec.MimeType)
 	}
 
-	logger.Log.Info("configured WebRTC to use PCMU codec only",
-		slog.String("component", "webrtc_config"))
+	if video {
+		if err := registerVideoCodecs(mediaEngine); err != nil {
+			logger.Log.Error("failed to register codec",
+				slog.String("component", "webrtc_config"),
+				slog.String("codec", webrtc.MimeTypeH264),
+				slog.String("error", err.Error()))
+			return nil, err
+		}
+	}
+
+	logger.Log.Info("configured WebRTC codecs",
+		slog.String("component", "webrtc_config"),
+		slog.String("codecs", strings.Join(mimeTypes, ",")),
+		slog.Bool("video", video))
 
 	return webrtc.NewAPI(
 		webrtc.WithSettingEngine(settingEngine),
@@ -116,8 +188,8 @@ func (c *WebRTCConfig) CreateAPI() (*webrtc.API, error) {
 }
 
 // CreatePeerConnection creates a new WebRTC peer connection with the configured API
-func (c *WebRTCConfig) CreatePeerConnection() (*webrtc.PeerConnection, error) {
-	api, err := c.CreateAPI()
+func (c *WebRTCConfig) CreatePeerConnection(codecs []audio.Codec, video bool) (*webrtc.PeerConnection, error) {
+	api, err := c.CreateAPI(codecs, video)
 	if err != nil {
 		return nil, err
 	}
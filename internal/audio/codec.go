@@ -0,0 +1,196 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Codec describes an audio codec understood by both the device and WebRTC
+type Codec struct {
+	// Name is the ISAPI audioCompressionType value (e.g. "G.711ulaw")
+	Name string
+
+	// MimeType is the WebRTC MIME type (e.g. "audio/PCMU")
+	MimeType string
+
+	// ClockRate is the RTP clock rate advertised in SDP
+	ClockRate uint32
+
+	// Channels is the number of channels advertised in SDP
+	Channels uint16
+
+	// SDPFmtpLine holds the format parameters advertised in SDP, if any
+	SDPFmtpLine string
+
+	// PayloadType is the RTP payload type
+	PayloadType uint8
+
+	// Bitrate is the bit rate of the device audio stream in bit/s
+	Bitrate int
+}
+
+// Supported device codecs
+var (
+	// PCMU is G.711 µ-law, the default codec
+	PCMU = Codec{Name: "G.711ulaw", MimeType: "audio/PCMU", ClockRate: 8000, Channels: 1, PayloadType: 0, Bitrate: 64000}
+
+	// PCMA is G.711 A-law
+	PCMA = Codec{Name: "G.711alaw", MimeType: "audio/PCMA", ClockRate: 8000, Channels: 1, PayloadType: 8, Bitrate: 64000}
+
+	// G722 is G.722 wideband (the RTP clock rate is 8000 for historical reasons)
+	G722 = Codec{Name: "G.722", MimeType: "audio/G722", ClockRate: 8000, Channels: 1, PayloadType: 9, Bitrate: 64000}
+
+	// G726 is G.726 ADPCM at 16 kbit/s. Browsers do not support it, so it is
+	// never negotiated with WebRTC clients and always transcoded.
+	G726 = Codec{Name: "G.726", MimeType: "audio/G726-16", ClockRate: 8000, Channels: 1, Bitrate: 16000}
+)
+
+// Opus is only accepted from WebRTC clients and decoded for the device, which
+// does not support it; clients are always sent G.711 or G.722. SDP always
+// advertises Opus as stereo at 48 kHz, whatever is actually sent.
+var Opus = Codec{Name: "Opus", MimeType: "audio/opus", ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10", PayloadType: 111}
+
+// Codecs lists all supported device codecs in order of preference
+var Codecs = []Codec{PCMU, PCMA, G722, G726}
+
+// ClientCodecs lists the codecs WebRTC clients can use in order of preference
+var ClientCodecs = []Codec{Opus, PCMU, PCMA, G722}
+
+// FrameSize returns the number of bytes that carry SampleDuration of device audio in the codec
+func (c Codec) FrameSize() int {
+	return int(time.Duration(c.Bitrate/8) * SampleDuration / time.Second)
+}
+
+// CodecByName looks up a codec by its ISAPI audioCompressionType value
+func CodecByName(name string) (Codec, bool) {
+	for _, c := range Codecs {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// CodecByMimeType looks up a client codec by its WebRTC MIME type
+func CodecByMimeType(mimeType string) (Codec, bool) {
+	for _, c := range ClientCodecs {
+		if strings.EqualFold(c.MimeType, mimeType) {
+			return c, true
+		}
+	}
+	return Codec{}, false
+}
+
+// CanTranscode reports whether audio can be converted from one codec to the other.
+// Opus can only be converted from, there is no encoder.
+func CanTranscode(from, to Codec) bool {
+	if from == to {
+		return true
+	}
+	return hasSamples(from) && hasSamples(to) && to != Opus
+}
+
+// Transcode converts audio between G.711 and G.726 codecs. Identical codecs
+// return the input unchanged. G.726 is adaptive, so data is a whole stream.
+func Transcode(from, to Codec, data []byte) ([]byte, error) {
+	if from == to {
+		return data, nil
+	}
+	if from == Opus || !CanTranscode(from, to) {
+		return nil, fmt.Errorf("cannot transcode from %s to %s", from.Name, to.Name)
+	}
+
+	samples, err := Decode(from, data)
+	if err != nil {
+		return nil, err
+	}
+	return Encode(to, samples)
+}
+
+// Transcoder converts a stream of audio between codecs. Unlike Transcode it
+// keeps the codec state from one call to the next, which Opus and G.726 need.
+type Transcoder struct {
+	from, to    Codec
+	opusDecoder *OpusDecoder
+	g726Decoder *G726Decoder
+	g726Encoder *G726Encoder
+}
+
+// NewTranscoder creates a transcoder from one codec to another
+func NewTranscoder(from, to Codec) (*Transcoder, error) {
+	if !CanTranscode(from, to) {
+		return nil, fmt.Errorf("cannot transcode from %s to %s", from.Name, to.Name)
+	}
+
+	t := &Transcoder{from: from, to: to}
+	if from == to {
+		return t, nil
+	}
+	switch from {
+	case Opus:
+		decoder, err := NewOpusDecoder()
+		if err != nil {
+			return nil, err
+		}
+		t.opusDecoder = decoder
+	case G726:
+		t.g726Decoder = NewG726Decoder()
+	}
+	if to == G726 {
+		t.g726Encoder = NewG726Encoder()
+	}
+	return t, nil
+}
+
+// Transcode converts the next piece of the stream. Opus input is one packet.
+func (t *Transcoder) Transcode(data []byte) ([]byte, error) {
+	if t.from == t.to {
+		return data, nil
+	}
+
+	var samples []int16
+	var err error
+	switch t.from {
+	case Opus:
+		samples, err = t.opusDecoder.Decode(data)
+	case G726:
+		samples = t.g726Decoder.Decode(data)
+	default:
+		samples, err = Decode(t.from, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.to == G726 {
+		return t.g726Encoder.Encode(samples), nil
+	}
+	return Encode(t.to, samples)
+}
+
+// SilenceFrame returns SampleSize bytes of silence in the codec. G.722 and
+// G.726 are adaptive, so their silence depends on the encoder state and there
+// is no such frame.
+func SilenceFrame(c Codec) ([]byte, bool) {
+	var silence byte
+	switch c {
+	case PCMU:
+		silence = 0xFF
+	case PCMA:
+		silence = 0xD5
+	default:
+		return nil, false
+	}
+	return bytes.Repeat([]byte{silence}, SampleSize), true
+}
+
+func isG711(c Codec) bool {
+	return c == PCMU || c == PCMA
+}
+
+// hasSamples reports whether audio in the codec can be decoded to samples and encoded back
+func hasSamples(c Codec) bool {
+	return isG711(c) || c == G726 || c == Opus
+}
@@ -0,0 +1,171 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/mewkiz/flac"
+)
+
+// FileFormat is the format of an audio file
+type FileFormat string
+
+const (
+	FileFormatRaw  FileFormat = "raw" // Headerless G.711 µ-law at SampleRate
+	FileFormatWAV  FileFormat = "wav"
+	FileFormatMP3  FileFormat = "mp3"
+	FileFormatFLAC FileFormat = "flac"
+	FileFormatOgg  FileFormat = "ogg" // Ogg Vorbis
+)
+
+// SniffFormat guesses the format of an audio file from its first bytes.
+// Anything unrecognised is taken as raw µ-law, which has no header.
+func SniffFormat(data []byte) FileFormat {
+	switch {
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return FileFormatWAV
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return FileFormatFLAC
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return FileFormatOgg
+	case bytes.HasPrefix(data, []byte("ID3")) || isMP3Header(data):
+		return FileFormatMP3
+	}
+	return FileFormatRaw
+}
+
+// isMP3Header reports whether data starts with a valid MPEG audio layer III
+// frame header. The fields are checked, not just the sync bits, because raw
+// µ-law silence is a run of 0xFF bytes.
+func isMP3Header(data []byte) bool {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return false
+	}
+	version := (data[1] >> 3) & 0x03
+	layer := (data[1] >> 1) & 0x03
+	bitrate := data[2] >> 4
+	sampleRate := (data[2] >> 2) & 0x03
+	return version != 1 && layer == 1 && bitrate != 0 && bitrate != 0x0F && sampleRate != 3
+}
+
+// DecodeFile decodes a WAV, MP3, FLAC or Ogg Vorbis file to mono samples at
+// SampleRate. Raw files are returned as µ-law by the caller, not decoded here.
+func DecodeFile(format FileFormat, data []byte) ([]int16, error) {
+	var samples []int16
+	var rate int
+	var err error
+
+	switch format {
+	case FileFormatWAV:
+		var wav *WAV
+		if wav, err = ReadWAV(bytes.NewReader(data)); err == nil {
+			samples, err = wav.PCM()
+			rate = wav.SampleRate
+		}
+	case FileFormatMP3:
+		samples, rate, err = decodeMP3(data)
+	case FileFormatFLAC:
+		samples, rate, err = decodeFLAC(data)
+	case FileFormatOgg:
+		samples, rate, err = decodeOgg(data)
+	default:
+		return nil, fmt.Errorf("cannot decode %s audio", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %w", format, err)
+	}
+
+	return Resample(samples, rate, SampleRate), nil
+}
+
+// decodeMP3 decodes an MP3 file; the decoder always outputs 16-bit stereo
+func decodeMP3(data []byte) ([]int16, int, error) {
+	dec, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var samples []int16
+	buf := make([]byte, 16*1024)
+	for {
+		n, err := dec.Read(buf)
+		samples = append(samples, Downmix(DecodePCM16(buf[:n]), 2)...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return samples, dec.SampleRate(), nil
+}
+
+// decodeFLAC decodes a FLAC file, keeping the 16 most significant bits of each sample
+func decodeFLAC(data []byte) ([]int16, int, error) {
+	stream, err := flac.New(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stream.Close()
+
+	shift := int(stream.Info.BitsPerSample) - 16
+	var samples []int16
+	for {
+		f, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		channels := len(f.Subframes)
+		for i := 0; i < int(f.BlockSize); i++ {
+			sum := 0
+			for _, sub := range f.Subframes {
+				s := int(sub.Samples[i])
+				if shift > 0 {
+					s >>= shift
+				} else {
+					s <<= -shift
+				}
+				sum += s
+			}
+			samples = append(samples, clampSample(sum/channels))
+		}
+	}
+	return samples, int(stream.Info.SampleRate), nil
+}
+
+// decodeOgg decodes an Ogg Vorbis file
+func decodeOgg(data []byte) ([]int16, int, error) {
+	r, err := oggvorbis.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	channels := r.Channels()
+	var samples []int16
+	buf := make([]float32, 4096*channels)
+	for {
+		n, err := r.Read(buf)
+		for i := 0; i+channels <= n; i += channels {
+			var sum float64
+			for _, s := range buf[i : i+channels] {
+				sum += float64(s)
+			}
+			samples = append(samples, floatSample(sum/float64(channels)))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return samples, r.SampleRate(), nil
+}
@@ -0,0 +1,57 @@
+package audio
+
+// SplitFrames splits audio into frames of size bytes. The last frame is shorter
+// if the audio does not divide evenly. The frames share the memory of data.
+func SplitFrames(data []byte, size int) [][]byte {
+	if size <= 0 {
+		return nil
+	}
+
+	frames := make([][]byte, 0, (len(data)+size-1)/size)
+	for len(data) > size {
+		frames = append(frames, data[:size:size])
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		frames = append(frames, data)
+	}
+	return frames
+}
+
+// Framer regroups audio arriving in chunks of any size into frames of a fixed size
+type Framer struct {
+	size int
+	buf  []byte
+}
+
+// NewFramer creates a framer for frames of size bytes (e.g. SampleSize for 20 ms)
+func NewFramer(size int) *Framer {
+	return &Framer{size: size}
+}
+
+// Write adds audio and returns the frames completed by it. The frames are
+// copies and stay valid after later calls.
+func (f *Framer) Write(p []byte) [][]byte {
+	f.buf = append(f.buf, p...)
+
+	var frames [][]byte
+	for len(f.buf) >= f.size {
+		frame := make([]byte, f.size)
+		copy(frame, f.buf)
+		frames = append(frames, frame)
+		f.buf = f.buf[f.size:]
+	}
+	return frames
+}
+
+// Buffered returns the number of bytes waiting for a frame to complete
+func (f *Framer) Buffered() int {
+	return len(f.buf)
+}
+
+// Flush returns the incomplete frame, if any, and empties the framer
+func (f *Framer) Flush() []byte {
+	rest := f.buf
+	f.buf = nil
+	return rest
+}
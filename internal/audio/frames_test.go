@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		data  int
+		sizes []int
+	}{
+		{"empty", SampleSize, 0, nil},
+		{"shorter than a frame", SampleSize, 100, []int{100}},
+		{"exact", SampleSize, 2 * SampleSize, []int{SampleSize, SampleSize}},
+		{"short tail", SampleSize, 2*SampleSize + 1, []int{SampleSize, SampleSize, 1}},
+		{"zero size", 0, 100, nil},
+		{"negative size", -1, 100, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, tt.data)
+			for i := range data {
+				data[i] = byte(i)
+			}
+
+			frames := SplitFrames(data, tt.size)
+			if len(frames) != len(tt.sizes) {
+				t.Fatalf("got %d frames, want %d", len(frames), len(tt.sizes))
+			}
+			var joined []byte
+			for i, frame := range frames {
+				if len(frame) != tt.sizes[i] {
+					t.Errorf("frame %d has %d bytes, want %d", i, len(frame), tt.sizes[i])
+				}
+				joined = append(joined, frame...)
+			}
+			if len(frames) > 0 && !bytes.Equal(joined, data) {
+				t.Error("frames do not add up to the data")
+			}
+		})
+	}
+}
+
+func TestSplitFramesCapacity(t *testing.T) {
+	// Appending to a frame must not overwrite the next one
+	data := bytes.Repeat([]byte{1}, 2*SampleSize)
+	frames := SplitFrames(data, SampleSize)
+	_ = append(frames[0], 9)
+	if frames[1][0] != 1 {
+		t.Error("appending to a frame overwrote the next one")
+	}
+}
+
+func TestFramer(t *testing.T) {
+	f := NewFramer(4)
+
+	if frames := f.Write([]byte{1, 2, 3}); len(frames) != 0 {
+		t.Fatalf("got %d frames from 3 bytes", len(frames))
+	}
+	frames := f.Write([]byte{4, 5, 6, 7, 8, 9})
+	if len(frames) != 2 || !bytes.Equal(frames[0], []byte{1, 2, 3, 4}) || !bytes.Equal(frames[1], []byte{5, 6, 7, 8}) {
+		t.Fatalf("frames = %v", frames)
+	}
+	if f.Buffered() != 1 {
+		t.Errorf("buffered = %d, want 1", f.Buffered())
+	}
+
+	// Frames stay valid after later writes
+	f.Write([]byte{10, 11})
+	if !bytes.Equal(frames[1], []byte{5, 6, 7, 8}) {
+		t.Errorf("frame changed to %v", frames[1])
+	}
+
+	if rest := f.Flush(); !bytes.Equal(rest, []byte{9, 10, 11}) {
+		t.Errorf("flush = %v", rest)
+	}
+	if f.Buffered() != 0 || f.Flush() != nil {
+		t.Error("framer not empty after flush")
+	}
+}
+
+func BenchmarkSplitFrames(b *testing.B) {
+	data := make([]byte, SampleRate*10) // 10 s of G.711
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		SplitFrames(data, SampleSize)
+	}
+}
+
+func BenchmarkFramer(b *testing.B) {
+	// RTP payloads that do not line up with 20 ms frames
+	chunk := make([]byte, 172)
+	f := NewFramer(SampleSize)
+	b.SetBytes(int64(len(chunk)))
+	for i := 0; i < b.N; i++ {
+		f.Write(chunk)
+	}
+}
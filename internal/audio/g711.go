@@ -0,0 +1,91 @@
+package audio
+
+const (
+	mulawBias = 0x84
+	mulawClip = 32635
+)
+
+// DecodeMulaw converts a G.711 µ-law byte to a 16-bit linear PCM sample
+func DecodeMulaw(u byte) int16 {
+	u = ^u
+	sign := u & 0x80
+	exponent := (u >> 4) & 0x07
+	mantissa := u & 0x0F
+
+	sample := ((int32(mantissa) << 3) + mulawBias) << exponent
+	sample -= mulawBias
+
+	if sign != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+// EncodeMulaw converts a 16-bit linear PCM sample to a G.711 µ-law byte
+func EncodeMulaw(sample int16) byte {
+	s := int32(sample)
+	sign := byte(0)
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	if s > mulawClip {
+		s = mulawClip
+	}
+	s += mulawBias
+
+	exponent := byte(7)
+	for mask := int32(0x4000); s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := byte(s>>(exponent+3)) & 0x0F
+
+	return ^(sign | exponent<<4 | mantissa)
+}
+
+// DecodeAlaw converts a G.711 A-law byte to a 16-bit linear PCM sample
+func DecodeAlaw(a byte) int16 {
+	a ^= 0x55
+	sign := a & 0x80
+	exponent := (a >> 4) & 0x07
+	mantissa := int32(a & 0x0F)
+
+	var sample int32
+	if exponent == 0 {
+		sample = mantissa<<4 + 8
+	} else {
+		sample = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+
+	if sign == 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+// EncodeAlaw converts a 16-bit linear PCM sample to a G.711 A-law byte
+func EncodeAlaw(sample int16) byte {
+	s := int32(sample)
+	sign := byte(0x80)
+	if s < 0 {
+		s = -s - 1
+		sign = 0
+	}
+	if s > 32767 {
+		s = 32767
+	}
+
+	var a byte
+	if s < 256 {
+		a = byte(s >> 4)
+	} else {
+		exponent := byte(7)
+		for mask := int32(0x4000); s&mask == 0 && exponent > 1; mask >>= 1 {
+			exponent--
+		}
+		mantissa := byte(s>>(exponent+3)) & 0x0F
+		a = exponent<<4 | mantissa
+	}
+
+	return (sign | a) ^ 0x55
+}
@@ -0,0 +1,131 @@
+package audio
+
+import "testing"
+
+// Reference values from the ITU-T G.711 tables
+var g711Reference = []struct {
+	name   string
+	decode func(byte) int16
+	code   byte
+	sample int16
+}{
+	{"mulaw", DecodeMulaw, 0xFF, 0},
+	{"mulaw", DecodeMulaw, 0x7F, 0},
+	{"mulaw", DecodeMulaw, 0x80, 32124},
+	{"mulaw", DecodeMulaw, 0x00, -32124},
+	{"mulaw", DecodeMulaw, 0x8F, 16764},
+	{"mulaw", DecodeMulaw, 0x0F, -16764},
+	{"mulaw", DecodeMulaw, 0xF0, 120},
+	{"mulaw", DecodeMulaw, 0xFE, 8},
+	{"alaw", DecodeAlaw, 0xD5, 8},
+	{"alaw", DecodeAlaw, 0x55, -8},
+	{"alaw", DecodeAlaw, 0xAA, 32256},
+	{"alaw", DecodeAlaw, 0x2A, -32256},
+	{"alaw", DecodeAlaw, 0xA5, 16896},
+	{"alaw", DecodeAlaw, 0xC5, 264},
+}
+
+func TestG711Decode(t *testing.T) {
+	for _, tt := range g711Reference {
+		if got := tt.decode(tt.code); got != tt.sample {
+			t.Errorf("%s: decode(%#02x) = %d, want %d", tt.name, tt.code, got, tt.sample)
+		}
+	}
+}
+
+func TestG711Encode(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(int16) byte
+		sample int16
+		code   byte
+	}{
+		{"mulaw", EncodeMulaw, 0, 0xFF},
+		{"mulaw", EncodeMulaw, 32767, 0x80},
+		{"mulaw", EncodeMulaw, -32768, 0x00},
+		{"mulaw", EncodeMulaw, 16764, 0x8F},
+		{"mulaw", EncodeMulaw, -16764, 0x0F},
+		{"alaw", EncodeAlaw, 0, 0xD5},
+		{"alaw", EncodeAlaw, -1, 0x55},
+		{"alaw", EncodeAlaw, 32767, 0xAA},
+		{"alaw", EncodeAlaw, -32768, 0x2A},
+		{"alaw", EncodeAlaw, 16896, 0xA5},
+	}
+	for _, tt := range tests {
+		if got := tt.encode(tt.sample); got != tt.code {
+			t.Errorf("%s: encode(%d) = %#02x, want %#02x", tt.name, tt.sample, got, tt.code)
+		}
+	}
+}
+
+func TestG711RoundTrip(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		b := byte(i)
+		// 0x7F is µ-law negative zero, which encodes back as positive zero
+		if got := EncodeMulaw(DecodeMulaw(b)); got != b && b != 0x7F {
+			t.Errorf("mulaw: %#02x decodes to %d, which encodes to %#02x", b, DecodeMulaw(b), got)
+		}
+		if got := EncodeAlaw(DecodeAlaw(b)); got != b {
+			t.Errorf("alaw: %#02x decodes to %d, which encodes to %#02x", b, DecodeAlaw(b), got)
+		}
+	}
+}
+
+func TestG711Quantization(t *testing.T) {
+	// Companding keeps the error within half a step, which grows with the level
+	for s := -32768; s <= 32767; s += 7 {
+		sample := int16(s)
+		for _, tt := range []struct {
+			name string
+			got  int16
+		}{
+			{"mulaw", DecodeMulaw(EncodeMulaw(sample))},
+			{"alaw", DecodeAlaw(EncodeAlaw(sample))},
+		} {
+			limit := abs(s)/16 + 16
+			if err := abs(int(tt.got) - s); err > limit {
+				t.Fatalf("%s: %d round trips to %d (error %d > %d)", tt.name, s, tt.got, err, limit)
+			}
+		}
+	}
+}
+
+func benchmarkSamples() []int16 {
+	samples := make([]int16, SampleRate) // 1 s
+	for i := range samples {
+		samples[i] = int16(i*37%65536 - 32768)
+	}
+	return samples
+}
+
+func BenchmarkEncodeMulaw(b *testing.B) {
+	samples := benchmarkSamples()
+	b.SetBytes(int64(2 * len(samples)))
+	for i := 0; i < b.N; i++ {
+		Encode(PCMU, samples)
+	}
+}
+
+func BenchmarkDecodeMulaw(b *testing.B) {
+	data, _ := Encode(PCMU, benchmarkSamples())
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Decode(PCMU, data)
+	}
+}
+
+func BenchmarkEncodeAlaw(b *testing.B) {
+	samples := benchmarkSamples()
+	b.SetBytes(int64(2 * len(samples)))
+	for i := 0; i < b.N; i++ {
+		Encode(PCMA, samples)
+	}
+}
+
+func BenchmarkDecodeAlaw(b *testing.B) {
+	data, _ := Encode(PCMA, benchmarkSamples())
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Decode(PCMA, data)
+	}
+}
@@ -0,0 +1,409 @@
+package audio
+
+// G.726 ADPCM at 16 kbit/s, following the ITU-T G.726 reference algorithm
+// (after the Sun Microsystems g72x implementation, with the 16 kbit/s tables
+// of the 2001 annex). Each sample is a 2-bit code; four codes are packed in a
+// byte, the first one in the least significant bits as RFC 3551 section 4.5.4
+// specifies.
+
+// g726SamplesPerByte is the number of 2-bit codes packed in a byte
+const g726SamplesPerByte = 4
+
+// g726QuantizerThreshold splits the normalized log prediction difference
+// between the small and the large code
+const g726QuantizerThreshold = 261
+
+var (
+	// g726Dqln maps a code to its reconstructed log magnitude
+	g726Dqln = [4]int{116, 365, 365, 116}
+
+	// g726Wi maps a code to the log of its scale factor multiplier
+	g726Wi = [4]int{-704, 14048, 14048, -704}
+
+	// g726Fi maps a code to its adaptation speed weight
+	g726Fi = [4]int{0x000, 0xE00, 0xE00, 0x000}
+
+	g726Power2 = [15]int{1, 2, 4, 8, 0x10, 0x20, 0x40, 0x80, 0x100, 0x200, 0x400, 0x800, 0x1000, 0x2000, 0x4000}
+)
+
+// g726State is the adaptive state shared by the encoder and the decoder. The
+// 16-bit fields hold values in the floating point formats of the standard.
+type g726State struct {
+	yl  int      // Locked quantizer scale factor
+	yu  int      // Unlocked quantizer scale factor
+	dms int      // Short term energy estimate
+	dml int      // Long term energy estimate
+	ap  int      // Linear weighting coefficient of yl and yu
+	a   [2]int16 // Pole predictor coefficients
+	b   [6]int16 // Zero predictor coefficients
+	pk  [2]int16 // Signs of the previous partial reconstructed signals
+	dq  [6]int16 // Previous quantized differences, 4-bit exponent and 6-bit mantissa
+	sr  [2]int16 // Previous reconstructed signals, 4-bit exponent and 6-bit mantissa
+	td  int      // Tone detect
+}
+
+func newG726State() g726State {
+	s := g726State{yl: 34816, yu: 544}
+	for i := range s.sr {
+		s.sr[i] = 32
+	}
+	for i := range s.dq {
+		s.dq[i] = 32
+	}
+	return s
+}
+
+// G726Encoder encodes a stream of mono samples at SampleRate as G.726
+type G726Encoder struct {
+	state   g726State
+	pending []int16 // Samples left over from the last call, less than a byte worth
+}
+
+// NewG726Encoder creates a G.726 encoder
+func NewG726Encoder() *G726Encoder {
+	return &G726Encoder{state: newG726State()}
+}
+
+// Encode encodes the samples, continuing the stream of the previous calls.
+// Samples that do not fill a byte are kept for the next call.
+func (e *G726Encoder) Encode(samples []int16) []byte {
+	if len(e.pending) > 0 {
+		samples = append(e.pending, samples...)
+		e.pending = nil
+	}
+
+	n := len(samples) / g726SamplesPerByte
+	data := make([]byte, n)
+	for i := range data {
+		var b byte
+		for j := 0; j < g726SamplesPerByte; j++ {
+			b |= e.state.encode(samples[i*g726SamplesPerByte+j]) << (2 * j)
+		}
+		data[i] = b
+	}
+
+	if rest := samples[n*g726SamplesPerByte:]; len(rest) > 0 {
+		e.pending = append([]int16(nil), rest...)
+	}
+	return data
+}
+
+// G726Decoder decodes a stream of G.726 audio to mono samples at SampleRate
+type G726Decoder struct {
+	state g726State
+}
+
+// NewG726Decoder creates a G.726 decoder
+func NewG726Decoder() *G726Decoder {
+	return &G726Decoder{state: newG726State()}
+}
+
+// Decode decodes the data, continuing the stream of the previous calls
+func (d *G726Decoder) Decode(data []byte) []int16 {
+	samples := make([]int16, 0, len(data)*g726SamplesPerByte)
+	for _, b := range data {
+		for j := 0; j < g726SamplesPerByte; j++ {
+			samples = append(samples, d.state.decode(b>>(2*j)&0x03))
+		}
+	}
+	return samples
+}
+
+// encode returns the code of the next sample
+func (s *g726State) encode(sample int16) byte {
+	sl := int(sample) >> 2 // 14-bit dynamic range
+
+	sezi := s.predictZero()
+	sez := sezi >> 1
+	se := (sezi + s.predictPole()) >> 1
+
+	d := sl - se
+	y := s.stepSize()
+	code := g726Quantize(d, y)
+	dq := g726Reconstruct(code&2 != 0, g726Dqln[code], y)
+
+	sr := se + dq
+	if dq < 0 {
+		sr = se - (dq & 0x3FFF)
+	}
+	s.update(y, g726Wi[code], g726Fi[code], dq, sr, sr+sez-se)
+	return byte(code)
+}
+
+// decode returns the next sample of a code
+func (s *g726State) decode(code byte) int16 {
+	sezi := s.predictZero()
+	sez := sezi >> 1
+	se := (sezi + s.predictPole()) >> 1
+
+	y := s.stepSize()
+	dq := g726Reconstruct(code&2 != 0, g726Dqln[code], y)
+
+	sr := se + dq
+	if dq < 0 {
+		sr = se - (dq & 0x3FFF)
+	}
+	s.update(y, g726Wi[code], g726Fi[code], dq, sr, sr+sez-se)
+	return clampSample(sr << 2)
+}
+
+// predictZero returns the sixth order zero predictor estimate
+func (s *g726State) predictZero() int {
+	sezi := 0
+	for i := range s.b {
+		sezi += g726Fmult(int(s.b[i])>>2, int(s.dq[i]))
+	}
+	return sezi
+}
+
+// predictPole returns the second order pole predictor estimate
+func (s *g726State) predictPole() int {
+	return g726Fmult(int(s.a[1])>>2, int(s.sr[1])) + g726Fmult(int(s.a[0])>>2, int(s.sr[0]))
+}
+
+// stepSize returns the quantizer scale factor, mixing the locked and unlocked factors
+func (s *g726State) stepSize() int {
+	if s.ap >= 256 {
+		return s.yu
+	}
+	y := s.yl >> 6
+	dif := s.yu - y
+	al := s.ap >> 2
+	if dif > 0 {
+		y += (dif * al) >> 6
+	} else if dif < 0 {
+		y += (dif*al + 0x3F) >> 6
+	}
+	return y
+}
+
+// update adapts the state to a reconstructed sample: y is the step size, wi and
+// fi the weights of the code, dq the quantized difference, sr the reconstructed
+// signal and dqsez the pole prediction difference
+func (s *g726State) update(y, wi, fi, dq, sr, dqsez int) {
+	pk0 := int16(0)
+	if dqsez < 0 {
+		pk0 = 1
+	}
+	mag := dq & 0x7FFF
+
+	// Transition detector
+	ylint := s.yl >> 15
+	ylfrac := (s.yl >> 10) & 0x1F
+	thr := (32 + ylfrac) << ylint
+	if ylint > 9 {
+		thr = 31 << 10
+	}
+	dqthr := (thr + (thr >> 1)) >> 1
+	tr := s.td != 0 && mag > dqthr
+
+	// Quantizer scale factor adaptation
+	s.yu = y + ((wi - y) >> 5)
+	if s.yu < 544 {
+		s.yu = 544
+	} else if s.yu > 5120 {
+		s.yu = 5120
+	}
+	s.yl += s.yu + ((-s.yl) >> 6)
+
+	// Adaptive predictor coefficients
+	var a2p int
+	if tr {
+		s.a = [2]int16{}
+		s.b = [6]int16{}
+	} else {
+		pks1 := pk0 ^ s.pk[0]
+
+		a2p = int(s.a[1]) - (int(s.a[1]) >> 7)
+		if dqsez != 0 {
+			fa1 := -int(s.a[0])
+			if pks1 != 0 {
+				fa1 = int(s.a[0])
+			}
+			switch {
+			case fa1 < -8191:
+				a2p -= 0x100
+			case fa1 > 8191:
+				a2p += 0xFF
+			default:
+				a2p += fa1 >> 5
+			}
+
+			if pk0^s.pk[1] != 0 {
+				switch {
+				case a2p <= -12160:
+					a2p = -12288
+				case a2p >= 12416:
+					a2p = 12288
+				default:
+					a2p -= 0x80
+				}
+			} else {
+				switch {
+				case a2p <= -12416:
+					a2p = -12288
+				case a2p >= 12160:
+					a2p = 12288
+				default:
+					a2p += 0x80
+				}
+			}
+		}
+		s.a[1] = int16(a2p)
+
+		a1 := int(s.a[0]) - (int(s.a[0]) >> 8)
+		if dqsez != 0 {
+			if pks1 == 0 {
+				a1 += 192
+			} else {
+				a1 -= 192
+			}
+		}
+		a1ul := 15360 - a2p
+		if a1 < -a1ul {
+			a1 = -a1ul
+		} else if a1 > a1ul {
+			a1 = a1ul
+		}
+		s.a[0] = int16(a1)
+
+		for i := range s.b {
+			bi := int(s.b[i]) - (int(s.b[i]) >> 8)
+			if mag != 0 {
+				if (dq ^ int(s.dq[i])) >= 0 {
+					bi += 128
+				} else {
+					bi -= 128
+				}
+			}
+			s.b[i] = int16(bi)
+		}
+	}
+
+	copy(s.dq[1:], s.dq[:5])
+	switch {
+	case mag == 0 && dq >= 0:
+		s.dq[0] = 0x20
+	case mag == 0:
+		s.dq[0] = -0x3E0 // 0xFC20
+	default:
+		exp := g726Quan(mag)
+		v := (exp << 6) + ((mag << 6) >> exp)
+		if dq < 0 {
+			v -= 0x400
+		}
+		s.dq[0] = int16(v)
+	}
+
+	s.sr[1] = s.sr[0]
+	switch {
+	case sr == 0:
+		s.sr[0] = 0x20
+	case sr > 0:
+		exp := g726Quan(sr)
+		s.sr[0] = int16((exp << 6) + ((sr << 6) >> exp))
+	case sr > -32768:
+		m := -sr
+		exp := g726Quan(m)
+		s.sr[0] = int16((exp << 6) + ((m << 6) >> exp) - 0x400)
+	default:
+		s.sr[0] = -0x3E0 // 0xFC20
+	}
+
+	s.pk[1] = s.pk[0]
+	s.pk[0] = pk0
+
+	// Tone detector
+	s.td = 0
+	if !tr && a2p < -11776 {
+		s.td = 1
+	}
+
+	// Adaptation speed control
+	s.dms += (fi - s.dms) >> 5
+	s.dml += ((fi << 2) - s.dml) >> 7
+	switch {
+	case tr:
+		s.ap = 256
+	case y < 1536, s.td == 1, abs((s.dms<<2)-s.dml) >= (s.dml >> 3):
+		s.ap += (0x200 - s.ap) >> 4
+	default:
+		s.ap += (-s.ap) >> 4
+	}
+}
+
+// g726Quantize returns the code of the prediction difference d for step size y
+func g726Quantize(d, y int) int {
+	dqm := abs(d)
+	exp := g726Quan(dqm >> 1)
+	mant := ((dqm << 7) >> exp) & 0x7F
+	dln := (exp << 7) + mant - (y >> 2)
+
+	code := 0
+	if dln >= g726QuantizerThreshold {
+		code = 1
+	}
+	if d < 0 {
+		return 3 - code
+	}
+	return code
+}
+
+// g726Reconstruct returns the quantized difference for a log magnitude and step size y
+func g726Reconstruct(negative bool, dqln, y int) int {
+	dql := dqln + (y >> 2)
+	if dql < 0 {
+		if negative {
+			return -0x8000
+		}
+		return 0
+	}
+	dex := (dql >> 7) & 15
+	dqt := 128 + (dql & 127)
+	dq := (dqt << 7) >> (14 - dex)
+	if negative {
+		return dq - 0x8000
+	}
+	return dq
+}
+
+// g726Fmult multiplies a predictor coefficient by a value in the 4-bit
+// exponent, 6-bit mantissa format
+func g726Fmult(an, srn int) int {
+	anmag := an
+	if an <= 0 {
+		anmag = (-an) & 0x1FFF
+	}
+	anexp := g726Quan(anmag) - 6
+	anmant := 32
+	if anmag != 0 {
+		if anexp >= 0 {
+			anmant = anmag >> anexp
+		} else {
+			anmant = anmag << -anexp
+		}
+	}
+	wanexp := anexp + ((srn >> 6) & 0xF) - 13
+	wanmant := (anmant*(srn&0x3F) + 0x30) >> 4
+
+	var retval int
+	if wanexp >= 0 {
+		retval = (wanmant << wanexp) & 0x7FFF
+	} else {
+		retval = wanmant >> -wanexp
+	}
+	if (an ^ srn) < 0 {
+		return -retval
+	}
+	return retval
+}
+
+// g726Quan returns the number of powers of two up to val
+func g726Quan(val int) int {
+	for i, p := range g726Power2 {
+		if val < p {
+			return i
+		}
+	}
+	return len(g726Power2)
+}
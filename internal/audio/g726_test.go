@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestG726EncodeDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     []int16
+		minSNR float64 // dB
+	}{
+		{"tone", tone(440, 8000, SampleRate, SampleRate), 18},
+		{"speech", speechLike(SampleRate), 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(G726, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(tt.in) / g726SamplesPerByte; len(data) != want {
+				t.Fatalf("encoded %d bytes, want %d", len(data), want)
+			}
+			out, err := Decode(G726, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Skip the first 100 ms while the step size adapts
+			if s := snr(tt.in, out, 0, SampleRate/10); s < tt.minSNR {
+				t.Errorf("SNR %.1f dB, want at least %.0f dB", s, tt.minSNR)
+			}
+		})
+	}
+}
+
+func TestG726Stream(t *testing.T) {
+	in := speechLike(SampleRate / 2)
+	whole, _ := Encode(G726, in)
+
+	// Pieces that do not fill whole bytes are carried over to the next call
+	enc := NewG726Encoder()
+	var pieces []byte
+	for i := 0; i < len(in); i += 37 {
+		pieces = append(pieces, enc.Encode(in[i:min(i+37, len(in))])...)
+	}
+	if !bytes.Equal(pieces, whole) {
+		t.Error("encoding in pieces differs from encoding at once")
+	}
+
+	dec := NewG726Decoder()
+	var out []int16
+	for i := 0; i < len(whole); i += 40 {
+		out = append(out, dec.Decode(whole[i:min(i+40, len(whole))])...)
+	}
+	if ref, _ := Decode(G726, whole); !equalSamples(out, ref) {
+		t.Error("decoding in pieces differs from decoding at once")
+	}
+}
+
+func TestG726Packing(t *testing.T) {
+	// The first code is in the least significant bits
+	codes := []byte{1, 2, 3, 0}
+	state := newG726State()
+	var want []int16
+	for _, c := range codes {
+		want = append(want, state.decode(c))
+	}
+	if got := NewG726Decoder().Decode([]byte{0x39}); !equalSamples(got, want) {
+		t.Errorf("decoded %v, want %v", got, want)
+	}
+
+	// A stream that does not fill the last byte is padded
+	if data, _ := Encode(G726, make([]int16, 5)); len(data) != 2 {
+		t.Errorf("5 samples encoded to %d bytes, want 2", len(data))
+	}
+}
+
+func TestG726Silence(t *testing.T) {
+	data, _ := Encode(G726, make([]int16, SampleRate/10))
+	samples, _ := Decode(G726, data)
+	if len(samples) != SampleRate/10 {
+		t.Fatalf("decoded %d samples, want %d", len(samples), SampleRate/10)
+	}
+	if level := rms(samples); level > 50 {
+		t.Errorf("silence decodes at level %.0f", level)
+	}
+}
+
+func BenchmarkG726Encode(b *testing.B) {
+	samples := speechLike(SampleRate)
+	b.SetBytes(int64(2 * len(samples)))
+	for i := 0; i < b.N; i++ {
+		Encode(G726, samples)
+	}
+}
+
+func BenchmarkG726Decode(b *testing.B) {
+	data, _ := Encode(G726, speechLike(SampleRate))
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Decode(G726, data)
+	}
+}
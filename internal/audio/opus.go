@@ -0,0 +1,34 @@
+package audio
+
+import (
+	"fmt"
+
+	"github.com/pion/opus"
+)
+
+// opusMaxFrameSamples is the longest audio an Opus packet carries (120 ms) at SampleRate
+const opusMaxFrameSamples = SampleRate * 120 / 1000
+
+// OpusDecoder decodes Opus packets of any mode and bandwidth to mono audio at SampleRate
+type OpusDecoder struct {
+	dec opus.Decoder
+	buf []int16
+}
+
+// NewOpusDecoder creates an Opus decoder
+func NewOpusDecoder() (*OpusDecoder, error) {
+	dec, err := opus.NewDecoderWithOutput(SampleRate, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &OpusDecoder{dec: dec, buf: make([]int16, opusMaxFrameSamples)}, nil
+}
+
+// Decode decodes a packet. The samples are only valid until the next call.
+func (d *OpusDecoder) Decode(packet []byte) ([]int16, error) {
+	n, err := d.dec.DecodeToInt16(packet, d.buf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid opus packet: %w", err)
+	}
+	return d.buf[:n], nil
+}
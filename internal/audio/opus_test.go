@@ -0,0 +1,92 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// opusTestPacket is a SILK-only narrowband 20 ms packet: the TOC byte followed
+// by the SILK frame of the pion/opus decoder tests
+var opusTestPacket = []byte{0x08, 0x0B, 0xE4, 0xC1, 0x36, 0xEC, 0xC5, 0x80}
+
+func TestOpusDecode(t *testing.T) {
+	dec, err := NewOpusDecoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := dec.Decode(opusTestPacket)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := SampleRate / 50; len(samples) != want {
+		t.Errorf("decoded %d samples, want %d", len(samples), want)
+	}
+
+	if _, err := dec.Decode([]byte{}); err == nil {
+		t.Error("expected an error for an empty packet")
+	}
+	// Without the TOC byte, the packet claims 36 frames, over the 120 ms limit
+	if _, err := dec.Decode(opusTestPacket[1:]); err == nil {
+		t.Error("expected an error for a malformed packet")
+	}
+}
+
+func TestOpusTranscode(t *testing.T) {
+	tr, err := NewTranscoder(Opus, PCMU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tr.Transcode(opusTestPacket)
+	if err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	if len(data) != SampleSize {
+		t.Errorf("transcoded to %d bytes, want %d", len(data), SampleSize)
+	}
+
+	// There is no Opus encoder
+	if CanTranscode(PCMU, Opus) {
+		t.Error("PCMU can be transcoded to Opus")
+	}
+	if _, err := NewTranscoder(PCMU, Opus); err == nil {
+		t.Error("expected an error transcoding to Opus")
+	}
+}
+
+// speechLike returns n samples of a voiced sound: a gliding pitch with
+// harmonics and a syllable-rate envelope
+func speechLike(n int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		t := float64(i) / SampleRate
+		pitch := 140 + 30*math.Sin(2*math.Pi*3*t)
+		v := 0.0
+		for h := 1; h <= 10; h++ {
+			v += math.Sin(2*math.Pi*pitch*float64(h)*t) / float64(h)
+		}
+		envelope := 0.6 + 0.4*math.Sin(2*math.Pi*4*t)
+		samples[i] = int16(5000 * envelope * v)
+	}
+	return samples
+}
+
+// snr returns the signal-to-noise ratio in dB of got, delayed by lag samples,
+// against ref, ignoring the first skip samples
+func snr(ref, got []int16, lag, skip int) float64 {
+	var signal, noise float64
+	for i := skip; i < len(ref) && i+lag < len(got); i++ {
+		d := float64(got[i+lag]) - float64(ref[i])
+		signal += float64(ref[i]) * float64(ref[i])
+		noise += d * d
+	}
+	return 10 * math.Log10(signal/noise)
+}
+
+func BenchmarkOpusDecode(b *testing.B) {
+	dec, _ := NewOpusDecoder()
+	b.SetBytes(int64(len(opusTestPacket)))
+	for i := 0; i < b.N; i++ {
+		dec.Decode(opusTestPacket)
+	}
+}
@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Samples are 16-bit signed linear PCM, mono unless a function says otherwise
+
+// DecodePCM16 converts little-endian 16-bit PCM bytes to samples. A trailing odd byte is ignored.
+func DecodePCM16(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	return samples
+}
+
+// EncodePCM16 converts samples to little-endian 16-bit PCM bytes
+func EncodePCM16(samples []int16) []byte {
+	data := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	return data
+}
+
+// Decode converts G.711 or G.726 audio to samples. G.726 is adaptive, so data
+// is decoded as a whole stream; use a G726Decoder for a stream in pieces.
+func Decode(codec Codec, data []byte) ([]int16, error) {
+	var decode func(byte) int16
+	switch codec {
+	case PCMU:
+		decode = DecodeMulaw
+	case PCMA:
+		decode = DecodeAlaw
+	case G726:
+		return NewG726Decoder().Decode(data), nil
+	default:
+		return nil, fmt.Errorf("cannot decode %s", codec.Name)
+	}
+
+	samples := make([]int16, len(data))
+	for i, b := range data {
+		samples[i] = decode(b)
+	}
+	return samples, nil
+}
+
+// Encode converts samples to G.711 or G.726 audio. G.726 samples are encoded
+// as a whole stream, padded with silence to fill the last byte.
+func Encode(codec Codec, samples []int16) ([]byte, error) {
+	var encode func(int16) byte
+	switch codec {
+	case PCMU:
+		encode = EncodeMulaw
+	case PCMA:
+		encode = EncodeAlaw
+	case G726:
+		enc := NewG726Encoder()
+		data := enc.Encode(samples)
+		if len(enc.pending) > 0 {
+			data = append(data, enc.Encode(make([]int16, g726SamplesPerByte-len(enc.pending)))...)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %s", codec.Name)
+	}
+
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		data[i] = encode(s)
+	}
+	return data, nil
+}
+
+// Downmix averages interleaved multi-channel samples into mono
+func Downmix(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		sum := 0
+		for _, s := range samples[i*channels : (i+1)*channels] {
+			sum += int(s)
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
+
+// Resample converts samples from one sample rate to another. Downsampling
+// averages the input over each output period, which filters out most of what
+// would alias; upsampling interpolates linearly. Both are fine for speech.
+func Resample(samples []int16, from, to int) []int16 {
+	if from == to || from <= 0 || to <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	out := make([]int16, n)
+
+	if from > to {
+		for i := range out {
+			start := int(int64(i) * int64(from) / int64(to))
+			end := int(int64(i+1) * int64(from) / int64(to))
+			if end > len(samples) {
+				end = len(samples)
+			}
+			if end <= start {
+				end = start + 1
+			}
+			sum := 0
+			for _, s := range samples[start:end] {
+				sum += int(s)
+			}
+			out[i] = int16(sum / (end - start))
+		}
+		return out
+	}
+
+	for i := range out {
+		// Position in the input, in 1/to steps
+		pos := int64(i) * int64(from)
+		j := int(pos / int64(to))
+		frac := pos % int64(to)
+		if j+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		a, b := int64(samples[j]), int64(samples[j+1])
+		out[i] = int16(a + (b-a)*frac/int64(to))
+	}
+	return out
+}
+
+// Mix adds src into dst sample by sample, clipping to the 16-bit range.
+// Samples of src beyond the length of dst are ignored.
+func Mix(dst, src []int16) {
+	for i := range dst {
+		if i >= len(src) {
+			return
+		}
+		dst[i] = clampSample(int(dst[i]) + int(src[i]))
+	}
+}
+
+// clampSample limits a sample to the 16-bit range
+func clampSample(s int) int16 {
+	switch {
+	case s > 32767:
+		return 32767
+	case s < -32768:
+		return -32768
+	}
+	return int16(s)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
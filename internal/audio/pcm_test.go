@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResampleLength(t *testing.T) {
+	tests := []struct {
+		from, to, in, want int
+	}{
+		{48000, 8000, 960, 160},
+		{44100, 8000, 882, 160},
+		{16000, 8000, 321, 160},
+		{8000, 16000, 160, 320},
+		{8000, 48000, 160, 960},
+	}
+	for _, tt := range tests {
+		got := Resample(make([]int16, tt.in), tt.from, tt.to)
+		if len(got) != tt.want {
+			t.Errorf("%d samples %d -> %d Hz: got %d samples, want %d", tt.in, tt.from, tt.to, len(got), tt.want)
+		}
+	}
+}
+
+func TestResampleUnchanged(t *testing.T) {
+	samples := []int16{1, 2, 3}
+	for _, rates := range [][2]int{{8000, 8000}, {0, 8000}, {8000, -1}} {
+		if got := Resample(samples, rates[0], rates[1]); !equalSamples(got, samples) {
+			t.Errorf("%d -> %d Hz: got %v, want the input", rates[0], rates[1], got)
+		}
+	}
+	if got := Resample(nil, 48000, 8000); len(got) != 0 {
+		t.Errorf("empty input: got %d samples", len(got))
+	}
+}
+
+func TestResampleTone(t *testing.T) {
+	// A 400 Hz tone keeps its level through downsampling and upsampling
+	for _, from := range []int{16000, 44100, 48000} {
+		in := tone(400, 8000, from, from/10)
+		out := Resample(in, from, SampleRate)
+		if got := rms(out[20:]); math.Abs(got-rms(in)) > 0.05*rms(in) {
+			t.Errorf("%d Hz: level %.0f, want %.0f", from, got, rms(in))
+		}
+		back := Resample(out, SampleRate, from)
+		if got := rms(back[from/100:]); math.Abs(got-rms(in)) > 0.05*rms(in) {
+			t.Errorf("%d Hz round trip: level %.0f, want %.0f", from, got, rms(in))
+		}
+	}
+}
+
+func TestDownmix(t *testing.T) {
+	if got := Downmix([]int16{100, 300, -32768, -32768, 7}, 2); !equalSamples(got, []int16{200, -32768}) {
+		t.Errorf("stereo: got %v", got)
+	}
+	if got := Downmix([]int16{1, 2, 3}, 1); !equalSamples(got, []int16{1, 2, 3}) {
+		t.Errorf("mono: got %v", got)
+	}
+}
+
+func TestMix(t *testing.T) {
+	dst := []int16{1000, 30000, -30000, 5}
+	Mix(dst, []int16{1000, 10000, -10000})
+	if !equalSamples(dst, []int16{2000, 32767, -32768, 5}) {
+		t.Errorf("got %v", dst)
+	}
+}
+
+// tone returns n samples of a sine wave at freq Hz and the given amplitude
+func tone(freq, amplitude float64, rate, n int) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return samples
+}
+
+func rms(samples []int16) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func BenchmarkResampleDown(b *testing.B) {
+	samples := tone(400, 8000, 48000, 48000)
+	b.SetBytes(int64(2 * len(samples)))
+	for i := 0; i < b.N; i++ {
+		Resample(samples, 48000, SampleRate)
+	}
+}
+
+func BenchmarkResampleUp(b *testing.B) {
+	samples := tone(400, 8000, SampleRate, SampleRate)
+	b.SetBytes(int64(2 * len(samples)))
+	for i := 0; i < b.N; i++ {
+		Resample(samples, SampleRate, 48000)
+	}
+}
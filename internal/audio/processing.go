@@ -0,0 +1,244 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Processing defaults and limits, in dB
+const (
+	DefaultTargetLoudness = -20.0 // dBFS RMS, loud but clear speech on the doorbell speaker
+	DefaultLimiterCeiling = -1.0  // dBFS
+
+	maxNormalizeGain = 20.0 // Quiet audio is boosted at most this much, so noise is not
+	maxGain          = 30.0
+	loudnessGate     = -50.0 // dBFS, quieter blocks are pauses and do not count towards loudness
+)
+
+// Processing time constants, in seconds
+const (
+	loudnessWindow = 3.0  // Live audio loudness is averaged over about this long
+	limiterRelease = 0.05 // Time for the limiter to recover from a peak
+	loudnessBlock  = 0.4  // Block length for measuring whole clips
+)
+
+// minBlockSamples is the shortest block worth measuring (10 ms)
+const minBlockSamples = SampleRate / 100
+
+// Processing configures the processing of audio played on the doorbell speaker.
+// The stages run in order: normalization, gain, limiter.
+type Processing struct {
+	// Normalize adjusts the loudness of the audio to TargetLoudness
+	Normalize bool
+
+	// TargetLoudness is the normalization target in dBFS RMS, zero for DefaultTargetLoudness
+	TargetLoudness float64
+
+	// Gain is applied after normalization, in dB
+	Gain float64
+
+	// Limiter keeps peaks below LimiterCeiling instead of letting them clip
+	Limiter bool
+
+	// LimiterCeiling is the highest peak level in dBFS, zero for DefaultLimiterCeiling
+	LimiterCeiling float64
+}
+
+// Enabled reports whether any stage changes the audio
+func (p Processing) Enabled() bool {
+	return p.Normalize || p.Gain != 0 || p.Limiter
+}
+
+// Validate checks that the settings are in range (NaN is not)
+func (p Processing) Validate() error {
+	if !(p.TargetLoudness >= -60 && p.TargetLoudness <= 0) {
+		return fmt.Errorf("target loudness must be between -60 and 0 dBFS")
+	}
+	if !(p.Gain >= -maxGain && p.Gain <= maxGain) {
+		return fmt.Errorf("gain must be between %g and %g dB", -maxGain, maxGain)
+	}
+	if !(p.LimiterCeiling >= -20 && p.LimiterCeiling <= 0) {
+		return fmt.Errorf("limiter ceiling must be between -20 and 0 dBFS")
+	}
+	return nil
+}
+
+// Processor applies Processing to a stream of samples. The loudness of live
+// audio is tracked as it plays, so normalization follows slow changes in level;
+// for a whole clip, Measure it first to apply one gain throughout.
+type Processor struct {
+	opts     Processing
+	loudness float64 // Mean power of the audio so far, relative to full scale; zero until measured
+	measured bool    // loudness holds the measure of a whole clip
+	normGain float64 // Normalization gain applied to the end of the last block
+	limGain  float64 // Current limiter gain reduction
+}
+
+// NewProcessor creates a processor
+func NewProcessor(opts Processing) *Processor {
+	if opts.TargetLoudness == 0 {
+		opts.TargetLoudness = DefaultTargetLoudness
+	}
+	if opts.LimiterCeiling == 0 {
+		opts.LimiterCeiling = DefaultLimiterCeiling
+	}
+	return &Processor{opts: opts, normGain: 1, limGain: 1}
+}
+
+// Measure sets the loudness used for normalization to that of a whole clip,
+// leaving out its pauses
+func (p *Processor) Measure(samples []int16) {
+	block := int(loudnessBlock * SampleRate)
+	var total float64
+	var blocks int
+	for start := 0; start < len(samples); start += block {
+		end := min(start+block, len(samples))
+		if end-start < minBlockSamples {
+			break
+		}
+		if power := meanPower(samples[start:end]); powerDB(power) > loudnessGate {
+			total += power
+			blocks++
+		}
+	}
+	if blocks > 0 {
+		p.loudness = total / float64(blocks)
+		p.measured = true
+		if p.opts.Normalize {
+			p.normGain = p.normalizeGain()
+		}
+	}
+}
+
+// Process processes samples in place
+func (p *Processor) Process(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+
+	// Normalization gain for this block, ramped from the last one to avoid clicks
+	from, to := p.normGain, p.normGain
+	if p.opts.Normalize {
+		if !p.measured {
+			p.track(samples)
+		}
+		if p.loudness > 0 {
+			to = p.normalizeGain()
+		}
+	}
+	p.normGain = to
+
+	gain := dbToGain(p.opts.Gain)
+	ceiling := 32768 * dbToGain(p.opts.LimiterCeiling)
+	release := 1 - math.Exp(-1/(limiterRelease*SampleRate))
+
+	for i, s := range samples {
+		norm := from + (to-from)*float64(i+1)/float64(len(samples))
+		v := float64(s) * norm * gain
+
+		if p.opts.Limiter {
+			// Instant attack, so no peak gets past the ceiling, and a smooth release
+			p.limGain += (1 - p.limGain) * release
+			if a := math.Abs(v) * p.limGain; a > ceiling {
+				p.limGain = ceiling / math.Abs(v)
+			}
+			v *= p.limGain
+		}
+		samples[i] = clampSample(int(math.Round(v)))
+	}
+}
+
+// normalizeGain returns the gain that brings the loudness to the target
+func (p *Processor) normalizeGain() float64 {
+	return dbToGain(min(p.opts.TargetLoudness-powerDB(p.loudness), maxNormalizeGain))
+}
+
+// track updates the loudness of live audio with a block of samples
+func (p *Processor) track(samples []int16) {
+	power := meanPower(samples)
+	if powerDB(power) <= loudnessGate {
+		return
+	}
+	if p.loudness == 0 {
+		p.loudness = power
+		return
+	}
+	alpha := 1 - math.Exp(-float64(len(samples))/(loudnessWindow*SampleRate))
+	p.loudness += (power - p.loudness) * alpha
+}
+
+// ProcessedWriter processes G.711 or G.726 audio before writing it to another writer
+type ProcessedWriter struct {
+	w     io.Writer
+	codec Codec
+	proc  *Processor
+
+	// G.726 is adaptive, so the audio is decoded and encoded again as one stream
+	g726Decoder *G726Decoder
+	g726Encoder *G726Encoder
+}
+
+// NewProcessedWriter creates a writer that processes audio in codec with proc
+// and writes it to w
+func NewProcessedWriter(w io.Writer, codec Codec, proc *Processor) (*ProcessedWriter, error) {
+	pw := &ProcessedWriter{w: w, codec: codec, proc: proc}
+	switch {
+	case codec == G726:
+		pw.g726Decoder = NewG726Decoder()
+		pw.g726Encoder = NewG726Encoder()
+	case !isG711(codec):
+		return nil, fmt.Errorf("cannot process %s audio", codec.Name)
+	}
+	return pw, nil
+}
+
+// Write processes p and writes it. Each byte holds whole samples, so the byte
+// counts are the same before and after processing.
+func (pw *ProcessedWriter) Write(p []byte) (int, error) {
+	if pw.codec == G726 {
+		samples := pw.g726Decoder.Decode(p)
+		pw.proc.Process(samples)
+		return pw.w.Write(pw.g726Encoder.Encode(samples))
+	}
+
+	samples, err := Decode(pw.codec, p)
+	if err != nil {
+		return 0, err
+	}
+	pw.proc.Process(samples)
+	data, err := Encode(pw.codec, samples)
+	if err != nil {
+		return 0, err
+	}
+	return pw.w.Write(data)
+}
+
+// Level returns the RMS level of samples in dBFS, minus infinity for silence
+func Level(samples []int16) float64 {
+	if len(samples) == 0 {
+		return math.Inf(-1)
+	}
+	return powerDB(meanPower(samples))
+}
+
+// meanPower returns the mean power of samples relative to full scale
+func meanPower(samples []int16) float64 {
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	return sum / float64(len(samples))
+}
+
+func powerDB(power float64) float64 {
+	if power <= 0 {
+		return math.Inf(-1)
+	}
+	return 10 * math.Log10(power)
+}
+
+func dbToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
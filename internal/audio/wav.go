@@ -0,0 +1,230 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// WAV format tags
+const (
+	WAVFormatPCM   = 1
+	WAVFormatFloat = 3
+	WAVFormatAlaw  = 6
+	WAVFormatMulaw = 7
+
+	wavFormatExtensible = 0xFFFE
+)
+
+// ErrNotWAV is returned when the data is not a RIFF/WAVE file
+var ErrNotWAV = errors.New("not a WAV file")
+
+// WAVFormat describes the audio of a WAV file
+type WAVFormat struct {
+	Format        uint16 // One of the WAVFormat tags
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+}
+
+// WAV is a decoded WAV file
+type WAV struct {
+	WAVFormat
+	Data []byte // Audio samples as stored in the file
+}
+
+// ReadWAV parses a WAV file. Files whose data chunk has no size, as written by
+// some streaming encoders, are read to the end.
+func ReadWAV(r io.Reader) (*WAV, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, ErrNotWAV
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	var wav WAV
+	haveFormat := false
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("WAV file has no data chunk")
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid WAV format chunk")
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("invalid WAV format chunk: %w", err)
+			}
+			wav.Format = binary.LittleEndian.Uint16(body[0:2])
+			wav.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			wav.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			wav.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			// WAVE_FORMAT_EXTENSIBLE keeps the real tag at the start of the sub-format GUID
+			if wav.Format == wavFormatExtensible && len(body) >= 26 {
+				wav.Format = binary.LittleEndian.Uint16(body[24:26])
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("WAV data chunk before format chunk")
+			}
+			var data []byte
+			var err error
+			if size == 0 || size == math.MaxUint32 {
+				data, err = io.ReadAll(r)
+			} else {
+				data = make([]byte, size)
+				var n int
+				n, err = io.ReadFull(r, data)
+				// Tolerate truncated files, keep what is there
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					data, err = data[:n], nil
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read WAV data: %w", err)
+			}
+			wav.Data = data
+			if err := wav.validate(); err != nil {
+				return nil, err
+			}
+			return &wav, nil
+
+		default:
+			// Chunks are padded to an even size
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size&1)); err != nil {
+				return nil, fmt.Errorf("WAV file has no data chunk")
+			}
+		}
+	}
+}
+
+// validate checks that the format is one we can decode
+func (w *WAV) validate() error {
+	if w.Channels < 1 || w.SampleRate < 1 {
+		return fmt.Errorf("invalid WAV format: %d channels at %d Hz", w.Channels, w.SampleRate)
+	}
+
+	switch {
+	case w.Format == WAVFormatPCM && (w.BitsPerSample == 8 || w.BitsPerSample == 16 || w.BitsPerSample == 24 || w.BitsPerSample == 32):
+	case w.Format == WAVFormatFloat && (w.BitsPerSample == 32 || w.BitsPerSample == 64):
+	case (w.Format == WAVFormatMulaw || w.Format == WAVFormatAlaw) && w.BitsPerSample == 8:
+	default:
+		return fmt.Errorf("unsupported WAV format %d with %d bits per sample", w.Format, w.BitsPerSample)
+	}
+	return nil
+}
+
+// Duration returns how long the audio plays for
+func (w *WAV) Duration() time.Duration {
+	frameSize := w.Channels * w.BitsPerSample / 8
+	return time.Duration(len(w.Data)/frameSize) * time.Second / time.Duration(w.SampleRate)
+}
+
+// PCM returns the audio as mono samples at the sample rate of the file
+func (w *WAV) PCM() ([]int16, error) {
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+
+	width := w.BitsPerSample / 8
+	samples := make([]int16, len(w.Data)/width)
+	for i := range samples {
+		b := w.Data[i*width : (i+1)*width]
+		switch {
+		case w.Format == WAVFormatMulaw:
+			samples[i] = DecodeMulaw(b[0])
+		case w.Format == WAVFormatAlaw:
+			samples[i] = DecodeAlaw(b[0])
+		case w.Format == WAVFormatFloat && width == 4:
+			samples[i] = floatSample(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		case w.Format == WAVFormatFloat:
+			samples[i] = floatSample(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		case width == 1:
+			// 8-bit PCM is unsigned
+			samples[i] = int16(int(b[0])-128) << 8
+		default:
+			// Keep the 16 most significant bits
+			samples[i] = int16(binary.LittleEndian.Uint16(b[width-2:]))
+		}
+	}
+
+	return Downmix(samples, w.Channels), nil
+}
+
+// Encode converts the audio to mono at SampleRate in the given G.711 codec
+func (w *WAV) Encode(codec Codec) ([]byte, error) {
+	if w.Channels == 1 && w.SampleRate == SampleRate &&
+		(codec == PCMU && w.Format == WAVFormatMulaw || codec == PCMA && w.Format == WAVFormatAlaw) {
+		return w.Data, nil
+	}
+
+	samples, err := w.PCM()
+	if err != nil {
+		return nil, err
+	}
+	return Encode(codec, Resample(samples, w.SampleRate, SampleRate))
+}
+
+// WriteWAV writes audio in the given format as a WAV file
+func WriteWAV(w io.Writer, format WAVFormat, data []byte) error {
+	if format.Channels < 1 || format.SampleRate < 1 || format.BitsPerSample < 8 {
+		return fmt.Errorf("invalid WAV format")
+	}
+	blockAlign := format.Channels * format.BitsPerSample / 8
+
+	var header bytes.Buffer
+	header.WriteString("RIFF")
+	binary.Write(&header, binary.LittleEndian, uint32(36+len(data)+len(data)&1))
+	header.WriteString("WAVEfmt ")
+	binary.Write(&header, binary.LittleEndian, uint32(16))
+	binary.Write(&header, binary.LittleEndian, format.Format)
+	binary.Write(&header, binary.LittleEndian, uint16(format.Channels))
+	binary.Write(&header, binary.LittleEndian, uint32(format.SampleRate))
+	binary.Write(&header, binary.LittleEndian, uint32(format.SampleRate*blockAlign))
+	binary.Write(&header, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&header, binary.LittleEndian, uint16(format.BitsPerSample))
+	header.WriteString("data")
+	binary.Write(&header, binary.LittleEndian, uint32(len(data)))
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if len(data)&1 == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
+
+// CodecWAVFormat returns the WAV format of mono audio in a G.711 codec
+func CodecWAVFormat(codec Codec) (WAVFormat, bool) {
+	switch codec {
+	case PCMU:
+		return WAVFormat{Format: WAVFormatMulaw, Channels: 1, SampleRate: SampleRate, BitsPerSample: 8}, true
+	case PCMA:
+		return WAVFormat{Format: WAVFormatAlaw, Channels: 1, SampleRate: SampleRate, BitsPerSample: 8}, true
+	}
+	return WAVFormat{}, false
+}
+
+// floatSample converts a sample in [-1, 1] to 16 bits, clipping beyond
+func floatSample(f float64) int16 {
+	return clampSample(int(math.Round(f * 32767)))
+}
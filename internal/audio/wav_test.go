@@ -0,0 +1,198 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	float32Data := func(values ...float32) []byte {
+		data := make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+		}
+		return data
+	}
+	float64Data := func(values ...float64) []byte {
+		data := make([]byte, 8*len(values))
+		for i, v := range values {
+			binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+		}
+		return data
+	}
+
+	tests := []struct {
+		name   string
+		format WAVFormat
+		data   []byte
+		want   []int16
+	}{
+		{
+			name:   "pcm16",
+			format: WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 16000, BitsPerSample: 16},
+			data:   EncodePCM16([]int16{0, 1000, -1000, 32767, -32768}),
+			want:   []int16{0, 1000, -1000, 32767, -32768},
+		},
+		{
+			// 8-bit PCM is unsigned, centered on 128
+			name:   "pcm8",
+			format: WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 8000, BitsPerSample: 8},
+			data:   []byte{128, 255, 0, 192},
+			want:   []int16{0, 32512, -32768, 16384},
+		},
+		{
+			name:   "pcm24",
+			format: WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 48000, BitsPerSample: 24},
+			data:   []byte{0xFF, 0x34, 0x12, 0x00, 0x00, 0x80},
+			want:   []int16{0x1234, -32768},
+		},
+		{
+			name:   "float32",
+			format: WAVFormat{Format: WAVFormatFloat, Channels: 1, SampleRate: 44100, BitsPerSample: 32},
+			data:   float32Data(0, 0.5, -0.5, 1, -1.5),
+			want:   []int16{0, 16384, -16384, 32767, -32768},
+		},
+		{
+			name:   "float64",
+			format: WAVFormat{Format: WAVFormatFloat, Channels: 1, SampleRate: 22050, BitsPerSample: 64},
+			data:   float64Data(0.25, -1, 2),
+			want:   []int16{8192, -32767, 32767},
+		},
+		{
+			name:   "stereo",
+			format: WAVFormat{Format: WAVFormatPCM, Channels: 2, SampleRate: 8000, BitsPerSample: 16},
+			data:   EncodePCM16([]int16{1000, 3000, -200, 200}),
+			want:   []int16{2000, 0},
+		},
+		{
+			name:   "mulaw",
+			format: WAVFormat{Format: WAVFormatMulaw, Channels: 1, SampleRate: 8000, BitsPerSample: 8},
+			data:   []byte{0xFF, 0x80, 0x00},
+			want:   []int16{0, 32124, -32124},
+		},
+		{
+			// An odd data size is padded in the file, not in the data
+			name:   "alaw odd",
+			format: WAVFormat{Format: WAVFormatAlaw, Channels: 1, SampleRate: 8000, BitsPerSample: 8},
+			data:   []byte{0xD5, 0xAA, 0x2A},
+			want:   []int16{8, 32256, -32256},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteWAV(&buf, tt.format, tt.data); err != nil {
+				t.Fatalf("WriteWAV: %v", err)
+			}
+			if buf.Len()%2 != 0 {
+				t.Errorf("file size %d is not even", buf.Len())
+			}
+
+			wav, err := ReadWAV(&buf)
+			if err != nil {
+				t.Fatalf("ReadWAV: %v", err)
+			}
+			if wav.WAVFormat != tt.format {
+				t.Errorf("format = %+v, want %+v", wav.WAVFormat, tt.format)
+			}
+			if !bytes.Equal(wav.Data, tt.data) {
+				t.Errorf("data = %x, want %x", wav.Data, tt.data)
+			}
+
+			samples, err := wav.PCM()
+			if err != nil {
+				t.Fatalf("PCM: %v", err)
+			}
+			if !equalSamples(samples, tt.want) {
+				t.Errorf("samples = %v, want %v", samples, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadWAVSkipsChunks(t *testing.T) {
+	var buf bytes.Buffer
+	WriteWAV(&buf, WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 8000, BitsPerSample: 16}, EncodePCM16([]int16{42}))
+	file := buf.Bytes()
+
+	// Insert an odd-sized LIST chunk, padded to an even size, before the data chunk
+	list := []byte("LIST\x03\x00\x00\x00abc\x00")
+	dataAt := bytes.Index(file, []byte("data"))
+	withList := append(append(append([]byte{}, file[:dataAt]...), list...), file[dataAt:]...)
+
+	wav, err := ReadWAV(bytes.NewReader(withList))
+	if err != nil {
+		t.Fatalf("ReadWAV: %v", err)
+	}
+	if samples, _ := wav.PCM(); !equalSamples(samples, []int16{42}) {
+		t.Errorf("samples = %v, want [42]", samples)
+	}
+}
+
+func TestReadWAVErrors(t *testing.T) {
+	if _, err := ReadWAV(bytes.NewReader([]byte("ID3\x04not a wav file"))); !errors.Is(err, ErrNotWAV) {
+		t.Errorf("non-WAV data: err = %v, want ErrNotWAV", err)
+	}
+
+	var buf bytes.Buffer
+	WriteWAV(&buf, WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 8000, BitsPerSample: 12}, []byte{0, 0})
+	if _, err := ReadWAV(&buf); err == nil {
+		t.Error("12-bit PCM: expected an error")
+	}
+
+	if err := WriteWAV(&buf, WAVFormat{Format: WAVFormatPCM}, nil); err == nil {
+		t.Error("WriteWAV with no channels: expected an error")
+	}
+}
+
+func TestWAVEncode(t *testing.T) {
+	// 16 kHz PCM is resampled to 8 kHz G.711
+	samples := make([]int16, 3200)
+	for i := range samples {
+		samples[i] = 4000
+	}
+	wav := &WAV{
+		WAVFormat: WAVFormat{Format: WAVFormatPCM, Channels: 1, SampleRate: 16000, BitsPerSample: 16},
+		Data:      EncodePCM16(samples),
+	}
+	data, err := wav.Encode(PCMU)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(data) != 1600 {
+		t.Fatalf("encoded %d bytes, want 1600", len(data))
+	}
+	if got := DecodeMulaw(data[800]); abs(int(got)-4000) > 128 {
+		t.Errorf("sample = %d, want about 4000", got)
+	}
+	if d := wav.Duration(); d != SampleDuration*10 {
+		t.Errorf("duration = %s, want 200ms", d)
+	}
+}
+
+func equalSamples(a, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func BenchmarkReadWAV(b *testing.B) {
+	var buf bytes.Buffer
+	WriteWAV(&buf, WAVFormat{Format: WAVFormatPCM, Channels: 2, SampleRate: 44100, BitsPerSample: 16}, make([]byte, 44100*4))
+	file := buf.Bytes()
+	b.SetBytes(int64(len(file)))
+	for i := 0; i < b.N; i++ {
+		wav, _ := ReadWAV(bytes.NewReader(file))
+		wav.Encode(PCMU)
+	}
+}
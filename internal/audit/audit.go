@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+)
+
+// Entry is a single audited action
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device,omitempty"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Result string    `json:"result"`
+	Remote string    `json:"remote,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Log records security-relevant actions to the application log and, optionally, a JSON lines file
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// New creates an audit log. If path is empty, entries are only written to the application log.
+func New(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	return l, nil
+}
+
+// Record writes an audit entry
+func (l *Log) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	logger.Log.Info("audit",
+		slog.String("component", "audit"),
+		slog.String("device", entry.Device),
+		slog.String("action", entry.Action),
+		slog.String("target", entry.Target),
+		slog.String("result", entry.Result),
+		slog.String("remote", entry.Remote),
+		slog.String("error", entry.Error))
+
+	if l.file == nil {
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		logger.Log.Error("failed to write audit entry",
+			slog.String("component", "audit"),
+			slog.String("error", err.Error()))
+	}
+}
+
+// Close closes the audit log file
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
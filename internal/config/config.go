@@ -1,25 +1,181 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"regexp"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Server    ServerConfig    `yaml:"server"`
+	Server ServerConfig `yaml:"server"`
+
+	// Hikvision configures a single doorbell (ignored when Devices is set)
 	Hikvision HikvisionConfig `yaml:"hikvision"`
+
+	// Devices configures several doorbells served by one instance
+	Devices []DeviceConfig `yaml:"devices"`
+
+	// DefaultDevice is the device served on the un-namespaced /api routes
+	// (defaults to the first device)
+	DefaultDevice string `yaml:"default_device"`
+
+	Audit AuditConfig `yaml:"audit"`
+
+	// TTS configures the text-to-speech backend of the say endpoint (optional)
+	TTS TTSConfig `yaml:"tts"`
 }
 
 type ServerConfig struct {
 	Host string `yaml:"host"`
 	Port int    `yaml:"port"`
+
+	// DoorToken must be sent as "Authorization: Bearer <token>" to unlock a door.
+	// Door unlock is disabled while it is empty.
+	DoorToken string `yaml:"door_token"`
+
+	// TrustedProxies are the reverse proxies (IPs or CIDRs) whose X-Forwarded-For
+	// header gives the client address in audit entries (optional)
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 type HikvisionConfig struct {
 	Host     string `yaml:"host"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// Scheme is "http" (default) or "https" for devices with HTTPS-only ISAPI
+	Scheme string `yaml:"scheme"`
+
+	// Port overrides the default port for the scheme (optional)
+	Port int `yaml:"port"`
+
+	// CAFile is a PEM bundle used to verify the device certificate (optional)
+	CAFile string `yaml:"ca_file"`
+
+	// PinnedFingerprints are SHA-256 fingerprints of accepted device certificates (optional).
+	// Use them for self-signed certificates instead of a CA bundle.
+	PinnedFingerprints []string `yaml:"pinned_fingerprints"`
+
+	// RequestTimeout bounds each ISAPI request, e.g. "10s" (optional)
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	// StreamConnectTimeout bounds establishing an audio or event stream, e.g. "5s" (optional)
+	StreamConnectTimeout time.Duration `yaml:"stream_connect_timeout"`
+
+	// AuthMaxFailures is the number of consecutive rejected logins after which
+	// requests are suspended, to avoid locking the device account (optional)
+	AuthMaxFailures int `yaml:"auth_max_failures"`
+
+	// AuthBackoff is how long requests are suspended after the first lockout,
+	// doubling with each further one, e.g. "1m" (optional)
+	AuthBackoff time.Duration `yaml:"auth_backoff"`
+
+	// Codec is the two-way audio codec to switch the device to (G.711ulaw, G.711alaw, G.722 or G.726).
+	// Empty keeps the device setting.
+	Codec string `yaml:"codec"`
+
+	// AutoAnswer answers a ringing call when a WebRTC session starts, so the
+	// doorbell stops ringing, and hangs it up when the session ends (optional)
+	AutoAnswer bool `yaml:"auto_answer"`
+
+	// Video is the RTSP stream sent to WebRTC clients that offer video:
+	// "main", "sub" (lower resolution) or empty to disable video
+	Video string `yaml:"video"`
+
+	// RTSPPort overrides the default RTSP port 554 (optional)
+	RTSPPort int `yaml:"rtsp_port"`
+
+	// AudioProcessing sets the default processing of audio played on the
+	// doorbell speaker, which requests can override (optional)
+	AudioProcessing AudioProcessingConfig `yaml:"audio_processing"`
+
+	// EchoSuppression attenuates the doorbell microphone while the WebRTC
+	// client speaks (optional)
+	EchoSuppression EchoSuppressionConfig `yaml:"echo_suppression"`
+}
+
+// EchoSuppressionConfig configures half-duplex echo suppression of WebRTC sessions
+type EchoSuppressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Threshold is the client audio level in dBFS taken as speech (default -40)
+	Threshold float64 `yaml:"threshold"`
+
+	// ReleaseThreshold is the level in dBFS the client audio must fall below
+	// for the speech to end (default 6 dB under Threshold)
+	ReleaseThreshold float64 `yaml:"release_threshold"`
+
+	// Attenuation is applied to the doorbell microphone while the client speaks,
+	// in dB (default 30)
+	Attenuation float64 `yaml:"attenuation"`
+
+	// HangTime keeps the microphone attenuated after the client speech ends, e.g. "300ms" (default)
+	HangTime time.Duration `yaml:"hang_time"`
+}
+
+// AudioProcessingConfig configures loudness normalization, gain and a peak
+// limiter for audio files and WebRTC client audio sent to the doorbell
+type AudioProcessingConfig struct {
+	// Normalize adjusts the loudness of the audio to TargetLoudness
+	Normalize bool `yaml:"normalize"`
+
+	// TargetLoudness is the normalization target in dBFS RMS (default -20)
+	TargetLoudness float64 `yaml:"target_loudness"`
+
+	// Gain is applied after normalization, in dB (optional)
+	Gain float64 `yaml:"gain"`
+
+	// Limiter keeps peaks below LimiterCeiling instead of letting them clip
+	Limiter bool `yaml:"limiter"`
+
+	// LimiterCeiling is the highest peak level in dBFS (default -1)
+	LimiterCeiling float64 `yaml:"limiter_ceiling"`
+}
+
+// DeviceConfig is a named doorbell in the devices list
+type DeviceConfig struct {
+	Name            string `yaml:"name"`
+	HikvisionConfig `yaml:",inline"`
+}
+
+// deviceNamePattern restricts device names to what can be used in a URL path segment
+var deviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// DefaultDeviceName is the name given to the device configured under "hikvision"
+const DefaultDeviceName = "default"
+
+type AuditConfig struct {
+	// Path is a file that audit entries are appended to as JSON lines (optional)
+	Path string `yaml:"path"`
+}
+
+// TTSConfig selects a text-to-speech backend: a local command or an HTTP service
+type TTSConfig struct {
+	// Command runs a local binary (e.g. piper or espeak-ng), followed by its
+	// arguments. The arguments may hold {text}, {voice}, {language} and {output}.
+	Command []string `yaml:"command"`
+
+	// URL is an HTTP service that returns the speech as an audio file
+	URL string `yaml:"url"`
+
+	// Method is the HTTP method of URL, POST (default, JSON body) or GET (query parameters)
+	Method string `yaml:"method"`
+
+	// Headers are added to the requests to URL (optional)
+	Headers map[string]string `yaml:"headers"`
+
+	// Voice and Language are used when a request does not set them (optional)
+	Voice    string `yaml:"voice"`
+	Language string `yaml:"language"`
+
+	// Timeout bounds each synthesis, e.g. "30s" (default)
+	Timeout time.Duration `yaml:"timeout"`
+
+	// CacheSize is the number of phrases kept for replay (default 100)
+	CacheSize int `yaml:"cache_size"`
 }
 
 func Load(path string) (*Config, error) {
@@ -33,5 +189,34 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// A single "hikvision" section is a device list of one
+	if len(cfg.Devices) == 0 {
+		cfg.Devices = []DeviceConfig{{
+			Name:            DefaultDeviceName,
+			HikvisionConfig: cfg.Hikvision,
+		}}
+	}
+
+	names := make(map[string]bool, len(cfg.Devices))
+	for _, d := range cfg.Devices {
+		if !deviceNamePattern.MatchString(d.Name) {
+			return nil, fmt.Errorf("invalid device name %q (use letters, digits, '-' and '_')", d.Name)
+		}
+		if names[d.Name] {
+			return nil, fmt.Errorf("duplicate device name %q", d.Name)
+		}
+		names[d.Name] = true
+	}
+
+	if cfg.DefaultDevice == "" {
+		cfg.DefaultDevice = cfg.Devices[0].Name
+	} else if !names[cfg.DefaultDevice] {
+		return nil, fmt.Errorf("default_device %q is not in the devices list", cfg.DefaultDevice)
+	}
+
+	if len(cfg.TTS.Command) > 0 && cfg.TTS.URL != "" {
+		return nil, fmt.Errorf("tts: set either command or url, not both")
+	}
+
 	return &cfg, nil
 }
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// Watch polls the configuration file and calls onChange with the new
+// configuration whenever its content changes, until ctx is cancelled.
+// Changes that fail to load are logged and ignored.
+func Watch(ctx context.Context, path string, interval time.Duration, onChange func(*Config)) {
+	last, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[Config] Failed to read %s: %v", path, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+
+		cfg, err := Load(path)
+		if err != nil {
+			log.Printf("[Config] Ignoring invalid configuration change: %v", err)
+			continue
+		}
+
+		log.Printf("[Config] Configuration changed")
+		onChange(cfg)
+	}
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber
+const subscriberBuffer = 16
+
+// Bus fans out published events to all current subscribers
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber and returns its channel and an unsubscribe function
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish delivers an event to every subscriber
+// Slow subscribers whose buffer is full miss the event instead of blocking the bus
+func (b *Bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			logger.Log.Warn("dropping event for slow subscriber",
+				slog.String("component", "event_bus"),
+				slog.String("type", event.Type))
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// Event represents a device event published on the bus
+type Event struct {
+	Device      string    `json:"device"`
+	Type        string    `json:"type"`
+	EventType   string    `json:"event_type"`
+	State       string    `json:"state"`
+	ChannelID   string    `json:"channel_id,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// EventSource produces device events and publishes them on a bus
+// This interface allows for different backend implementations (Hikvision, Dahua, etc.)
+type EventSource interface {
+	// Run publishes events until the context is cancelled
+	Run(ctx context.Context) error
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+)
+
+// HikvisionEventSource implements EventSource for Hikvision devices
+type HikvisionEventSource struct {
+	device string
+	client *hikvision.Client
+	bus    *Bus
+}
+
+// NewHikvisionEventSource creates a new Hikvision event source for the named device
+func NewHikvisionEventSource(device string, client *hikvision.Client, bus *Bus) *HikvisionEventSource {
+	return &HikvisionEventSource{
+		device: device,
+		client: client,
+		bus:    bus,
+	}
+}
+
+// Run reads the device alert stream and publishes events until the context is cancelled
+func (s *HikvisionEventSource) Run(ctx context.Context) error {
+	reader := s.client.NewEventStreamReader()
+	reader.Start(ctx)
+	defer reader.Close()
+
+	logger.Log.Info("started event source",
+		slog.String("component", "event_source"),
+		slog.String("device", s.device))
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Info("event source stopped",
+				slog.String("component", "event_source"))
+			return ctx.Err()
+		case ev, ok := <-reader.Events():
+			if !ok {
+				return nil
+			}
+
+			logger.Log.Info("received device event",
+				slog.String("component", "event_source"),
+				slog.String("device", s.device),
+				slog.String("kind", string(ev.Kind)),
+				slog.String("event_type", ev.EventType),
+				slog.String("state", ev.State))
+
+			s.bus.Publish(Event{
+				Device:      s.device,
+				Type:        string(ev.Kind),
+				EventType:   ev.EventType,
+				State:       ev.State,
+				ChannelID:   ev.ChannelID,
+				Description: ev.Description,
+				Time:        ev.Time,
+			})
+		}
+	}
+}
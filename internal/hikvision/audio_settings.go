@@ -0,0 +1,142 @@
+package hikvision
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+)
+
+// DefaultAudioChannel is the audio input/output channel of single-speaker doorbells
+const DefaultAudioChannel = "1"
+
+// rawElement preserves an XML element we do not model, so settings can be
+// written back without resetting fields we never read
+type rawElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// MarshalXML writes the element back without the namespace the decoder attached to it
+func (e rawElement) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	type plain rawElement
+	return enc.Encode(plain{
+		XMLName: xml.Name{Local: e.XMLName.Local},
+		Attrs:   e.Attrs,
+		Inner:   e.Inner,
+	})
+}
+
+// AudioInVolume is a volume entry of an audio input (the element name typo is the device's)
+type AudioInVolume struct {
+	Type   string `xml:"type"`
+	Volume int    `xml:"volume"`
+}
+
+// AudioIn represents /ISAPI/System/Audio/AudioIn/channels/{id} (microphone)
+type AudioIn struct {
+	XMLName     xml.Name        `xml:"AudioIn"`
+	ID          string          `xml:"id"`
+	Volumes     []AudioInVolume `xml:"AudioInVolumelist>AudioInVlome"`
+	NoiseReduce string          `xml:"noisereduce,omitempty"`
+	Extra       []rawElement    `xml:",any"`
+}
+
+// Volume returns the microphone volume
+func (a *AudioIn) Volume() int {
+	if len(a.Volumes) == 0 {
+		return 0
+	}
+	return a.Volumes[0].Volume
+}
+
+// SetVolume changes the microphone volume
+func (a *AudioIn) SetVolume(volume int) {
+	if len(a.Volumes) == 0 {
+		a.Volumes = []AudioInVolume{{Type: "audioInput"}}
+	}
+	a.Volumes[0].Volume = volume
+}
+
+// AudioOutVolume is a volume entry of an audio output (the element name typo is the device's)
+type AudioOutVolume struct {
+	Type       string `xml:"type"`
+	Volume     int    `xml:"volume"`
+	TalkVolume *int   `xml:"talkVolume,omitempty"`
+}
+
+// AudioOut represents /ISAPI/System/Audio/AudioOut/channels/{id} (speaker)
+type AudioOut struct {
+	XMLName xml.Name         `xml:"AudioOut"`
+	ID      string           `xml:"id"`
+	Volumes []AudioOutVolume `xml:"AudioOutVolumelist>AudioOutVlome"`
+	Extra   []rawElement     `xml:",any"`
+}
+
+// Volume returns the speaker volume for prompts and ringing
+func (a *AudioOut) Volume() int {
+	if len(a.Volumes) == 0 {
+		return 0
+	}
+	return a.Volumes[0].Volume
+}
+
+// SetVolume changes the speaker volume for prompts and ringing
+func (a *AudioOut) SetVolume(volume int) {
+	if len(a.Volumes) == 0 {
+		a.Volumes = []AudioOutVolume{{Type: "audioOutput"}}
+	}
+	a.Volumes[0].Volume = volume
+}
+
+// TalkVolume returns the speaker volume during two-way audio, if the device has a separate one
+func (a *AudioOut) TalkVolume() (int, bool) {
+	if len(a.Volumes) == 0 || a.Volumes[0].TalkVolume == nil {
+		return 0, false
+	}
+	return *a.Volumes[0].TalkVolume, true
+}
+
+// SetTalkVolume changes the speaker volume during two-way audio
+func (a *AudioOut) SetTalkVolume(volume int) {
+	if len(a.Volumes) == 0 {
+		a.Volumes = []AudioOutVolume{{Type: "audioOutput"}}
+	}
+	a.Volumes[0].TalkVolume = &volume
+}
+
+// GetAudioInput retrieves the microphone volume and noise reduction settings
+func (c *Client) GetAudioInput(ctx context.Context, channelID string) (*AudioIn, error) {
+	var in AudioIn
+	if err := c.getXML(ctx, "GetAudioInput", "/ISAPI/System/Audio/AudioIn/channels/"+channelID, &in); err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+// SetAudioInput writes the microphone settings, typically after modifying the result of GetAudioInput
+func (c *Client) SetAudioInput(ctx context.Context, channelID string, in *AudioIn) error {
+	if err := c.putXML(ctx, "SetAudioInput", "/ISAPI/System/Audio/AudioIn/channels/"+channelID, in); err != nil {
+		return err
+	}
+	log.Printf("[Hikvision] SetAudioInput: Channel %s volume %d, noise reduction %q", channelID, in.Volume(), in.NoiseReduce)
+	return nil
+}
+
+// GetAudioOutput retrieves the speaker volume settings
+func (c *Client) GetAudioOutput(ctx context.Context, channelID string) (*AudioOut, error) {
+	var out AudioOut
+	if err := c.getXML(ctx, "GetAudioOutput", "/ISAPI/System/Audio/AudioOut/channels/"+channelID, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetAudioOutput writes the speaker settings, typically after modifying the result of GetAudioOutput
+func (c *Client) SetAudioOutput(ctx context.Context, channelID string, out *AudioOut) error {
+	if err := c.putXML(ctx, "SetAudioOutput", "/ISAPI/System/Audio/AudioOut/channels/"+channelID, out); err != nil {
+		return err
+	}
+	log.Printf("[Hikvision] SetAudioOutput: Channel %s volume %d", channelID, out.Volume())
+	return nil
+}
@@ -0,0 +1,177 @@
+package hikvision
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAuthMaxFailures is low because the device counts every rejected
+	// digest attempt, and a single request can make two of them
+	defaultAuthMaxFailures = 2
+	defaultAuthBackoff     = time.Minute
+	maxAuthBackoff         = time.Hour
+)
+
+// AuthStatus reports the state of the credential-failure tracker
+type AuthStatus struct {
+	// LockedOut is true while requests are suspended after repeated auth failures
+	LockedOut bool
+
+	// Failures is the number of consecutive authentication failures
+	Failures int
+
+	// RetryAt is when the credentials will be tried again (zero if not locked out)
+	RetryAt time.Time
+}
+
+// authGuard stops requests after repeated authentication failures so a wrong
+// password does not get the device account locked. Once the backoff expires,
+// a single request is let through to test the credentials again; each further
+// failure doubles the backoff.
+type authGuard struct {
+	maxFailures int
+	backoff     time.Duration
+
+	mu          sync.Mutex
+	failures    int       // Consecutive authentication failures
+	lockouts    int       // Consecutive lockouts, doubles the backoff each time
+	lockedUntil time.Time // Requests are rejected until then
+	probing     bool      // A request is testing the credentials after the backoff
+}
+
+func newAuthGuard(maxFailures int, backoff time.Duration) *authGuard {
+	if maxFailures <= 0 {
+		maxFailures = defaultAuthMaxFailures
+	}
+	if backoff <= 0 {
+		backoff = defaultAuthBackoff
+	}
+	return &authGuard{
+		maxFailures: maxFailures,
+		backoff:     backoff,
+	}
+}
+
+// allow returns ErrAuthLockedOut if requests are currently suspended
+func (g *authGuard) allow() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures < g.maxFailures {
+		return nil
+	}
+	if g.probing || time.Now().Before(g.lockedUntil) {
+		return ErrAuthLockedOut
+	}
+
+	log.Printf("[Hikvision] AuthGuard: Backoff expired, testing credentials again")
+	g.probing = true
+	return nil
+}
+
+// record updates the tracker with the outcome of a request let through by allow
+func (g *authGuard) record(resp *http.Response, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case err != nil:
+		// Network errors say nothing about the credentials
+		g.probing = false
+
+	case resp.StatusCode == http.StatusUnauthorized:
+		g.probing = false
+		g.failures++
+		if g.failures < g.maxFailures {
+			log.Printf("[Hikvision] AuthGuard: Authentication failed (%d/%d)", g.failures, g.maxFailures)
+			return
+		}
+
+		backoff := g.backoff << g.lockouts
+		if backoff > maxAuthBackoff || backoff <= 0 {
+			backoff = maxAuthBackoff
+		}
+		g.lockouts++
+		g.lockedUntil = time.Now().Add(backoff)
+		log.Printf("[Hikvision] AuthGuard: %d consecutive authentication failures, suspending requests for %s",
+			g.failures, backoff)
+
+	default:
+		if g.failures >= g.maxFailures {
+			log.Printf("[Hikvision] AuthGuard: Credentials accepted, resuming requests")
+		}
+		g.failures = 0
+		g.lockouts = 0
+		g.lockedUntil = time.Time{}
+		g.probing = false
+	}
+}
+
+// reset clears all failures, e.g. after the credentials were changed
+func (g *authGuard) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.failures = 0
+	g.lockouts = 0
+	g.lockedUntil = time.Time{}
+	g.probing = false
+}
+
+func (g *authGuard) status() AuthStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures < g.maxFailures {
+		return AuthStatus{Failures: g.failures}
+	}
+	return AuthStatus{
+		LockedOut: true,
+		Failures:  g.failures,
+		RetryAt:   g.lockedUntil,
+	}
+}
+
+// AuthStatus returns the state of the credential-failure tracker
+func (c *Client) AuthStatus() AuthStatus {
+	return c.guard.status()
+}
+
+// SetCredentials replaces the credentials used for the device. Only new
+// credentials lift an auth lockout: retrying the rejected ones could lock the
+// device account.
+func (c *Client) SetCredentials(username, password string) {
+	c.credMu.Lock()
+	changed := username != c.username || password != c.password
+	c.username = username
+	c.password = password
+	c.credMu.Unlock()
+
+	if changed {
+		c.guard.reset()
+		log.Printf("[Hikvision] Credentials updated for user %s", username)
+	}
+}
+
+// credentials returns the current username and password
+func (c *Client) credentials() (string, string) {
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+	return c.username, c.password
+}
+
+// newAuthTransport wraps a transport with digest auth using the current
+// credentials and the shared nonce, the auth guard and the retry for empty challenges
+func (c *Client) newAuthTransport(transport http.RoundTripper) http.RoundTripper {
+	return &retryRoundTripper{
+		transport: &digestTransport{
+			transport:   transport,
+			nonces:      c.nonces,
+			credentials: c.credentials,
+		},
+		guard: c.guard,
+	}
+}
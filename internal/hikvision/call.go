@@ -0,0 +1,115 @@
+package hikvision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// CallState is the video intercom call state reported by the device
+type CallState string
+
+const (
+	CallStateIdle    CallState = "idle"
+	CallStateRinging CallState = "ring"
+	CallStateOnCall  CallState = "onCall"
+)
+
+// CallCommand is a callSignal command sent to the device
+type CallCommand string
+
+const (
+	CallCommandAnswer CallCommand = "answer"
+	CallCommandHangUp CallCommand = "hangUp"
+	CallCommandReject CallCommand = "reject"
+)
+
+// callStatus is the JSON body of /ISAPI/VideoIntercom/callStatus
+type callStatus struct {
+	CallStatus struct {
+		Status CallState `json:"status"`
+	} `json:"CallStatus"`
+}
+
+// callSignal is the JSON body of /ISAPI/VideoIntercom/callSignal
+type callSignal struct {
+	CallSignal struct {
+		CmdType CallCommand `json:"cmdType"`
+	} `json:"CallSignal"`
+}
+
+// GetCallStatus returns whether the doorbell is idle, ringing or in a call
+func (c *Client) GetCallStatus(ctx context.Context) (CallState, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url("/ISAPI/VideoIntercom/callStatus?format=json"), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] GetCallStatus: Request failed: %v", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Hikvision] GetCallStatus: Error response body: %s", string(body))
+		return "", newError("GetCallStatus", resp.StatusCode, body)
+	}
+
+	var status callStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		log.Printf("[Hikvision] GetCallStatus: Failed to parse JSON: %v", err)
+		return "", err
+	}
+
+	return status.CallStatus.Status, nil
+}
+
+// SendCallSignal answers, hangs up or rejects the current call
+func (c *Client) SendCallSignal(ctx context.Context, cmd CallCommand) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	var signal callSignal
+	signal.CallSignal.CmdType = cmd
+	body, err := json.Marshal(signal)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.url("/ISAPI/VideoIntercom/callSignal?format=json"), bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[Hikvision] SendCallSignal: Failed to create request: %v", err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] SendCallSignal: Request failed: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Printf("[Hikvision] SendCallSignal: Error response body: %s", string(respBody))
+		return newError("SendCallSignal", resp.StatusCode, respBody)
+	}
+
+	log.Printf("[Hikvision] SendCallSignal: Sent %s", cmd)
+	return nil
+}
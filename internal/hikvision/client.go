@@ -1,23 +1,76 @@
 package hikvision
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-
-	"github.com/icholy/digest"
+	"strconv"
+	"sync"
+	"time"
 )
 
 // Client handles communication with Hikvision ISAPI
 type Client struct {
-	host     string
+	host      string
+	baseURL   string
+	tlsConfig *tls.Config // nil when the device is reached over plain HTTP
+	client    *http.Client
+	guard     *authGuard // Suspends requests after repeated auth failures
+
+	nonces      *nonceCache     // Digest challenge shared by all requests
+	streamPool  *streamConnPool // Connections opened ahead of time for audio streams
+	streamStats streamStats
+
+	credMu   sync.RWMutex
 	username string
 	password string
-	client   *http.Client
+
+	requestTimeout       time.Duration // Deadline for a single ISAPI request
+	streamConnectTimeout time.Duration // Deadline for establishing an audio or event stream
+}
+
+// ClientOptions holds optional connection settings for the device
+type ClientOptions struct {
+	// Scheme is "http" (default) or "https"
+	Scheme string
+
+	// Port overrides the default port for the scheme
+	Port int
+
+	// CAFile is a PEM bundle used to verify the device certificate (https only)
+	CAFile string
+
+	// PinnedFingerprints are SHA-256 fingerprints of accepted device certificates (https only).
+	// When set, the certificate chain is only verified if CAFile is also set.
+	PinnedFingerprints []string
+
+	// RequestTimeout bounds each ISAPI request such as listing, opening or
+	// closing channels (default 10s)
+	RequestTimeout time.Duration
+
+	// StreamConnectTimeout bounds establishing a long-lived audio or event
+	// stream; once connected, streams live until closed (default 5s)
+	StreamConnectTimeout time.Duration
+
+	// AuthMaxFailures is the number of consecutive authentication failures
+	// after which requests are suspended (default 2)
+	AuthMaxFailures int
+
+	// AuthBackoff is how long requests are suspended after the first lockout;
+	// it doubles with each further one, up to an hour (default 1m)
+	AuthBackoff time.Duration
 }
 
+const (
+	defaultRequestTimeout       = 10 * time.Second
+	defaultStreamConnectTimeout = 5 * time.Second
+)
+
 // TwoWayAudioChannelList represents the list of available two-way audio channels
 type TwoWayAudioChannelList struct {
 	XMLName  xml.Name             `xml:"TwoWayAudioChannelList"`
@@ -26,20 +79,22 @@ type TwoWayAudioChannelList struct {
 
 // TwoWayAudioChannel represents a single two-way audio channel
 type TwoWayAudioChannel struct {
-	ID                   string `xml:"id"`
-	Enabled              string `xml:"enabled"`
-	AudioInputID         string `xml:"audioInputID"`
-	AudioOutputID        string `xml:"audioOutputID"`
-	AudioCompressionType string `xml:"audioCompressionType"`
+	XMLName              xml.Name     `xml:"TwoWayAudioChannel"`
+	ID                   string       `xml:"id"`
+	Enabled              string       `xml:"enabled"`
+	AudioInputID         string       `xml:"audioInputID,omitempty"`
+	AudioOutputID        string       `xml:"audioOutputID,omitempty"`
+	AudioCompressionType string       `xml:"audioCompressionType"`
+	Extra                []rawElement `xml:",any"`
 }
 
-// ResponseStatus represents ISAPI response status
+// ResponseStatus represents ISAPI response status (XML, or JSON for format=json endpoints)
 type ResponseStatus struct {
-	XMLName       xml.Name `xml:"ResponseStatus"`
-	RequestURL    string   `xml:"requestURL"`
-	StatusCode    int      `xml:"statusCode"`
-	StatusString  string   `xml:"statusString"`
-	SubStatusCode string   `xml:"subStatusCode"`
+	XMLName       xml.Name `xml:"ResponseStatus" json:"-"`
+	RequestURL    string   `xml:"requestURL" json:"requestURL"`
+	StatusCode    int      `xml:"statusCode" json:"statusCode"`
+	StatusString  string   `xml:"statusString" json:"statusString"`
+	SubStatusCode string   `xml:"subStatusCode" json:"subStatusCode"`
 }
 
 // AudioSession represents an active two-way audio session
@@ -55,34 +110,91 @@ type TwoWayAudioSession struct {
 }
 
 // NewClient creates a new Hikvision ISAPI client
-func NewClient(host, username, password string) *Client {
-	// Create a digest transport that will handle auth challenges
-	transport := &digest.Transport{
-		Username: username,
-		Password: password,
+func NewClient(host, username, password string, opts ClientOptions) (*Client, error) {
+	scheme := opts.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", scheme)
+	}
+
+	if opts.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(opts.Port))
+	}
+
+	var tlsConfig *tls.Config
+	if scheme == "https" {
+		var err error
+		tlsConfig, err = newTLSConfig(opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Wrap in a custom RoundTripper that logs auth challenges
-	retryTransport := &retryRoundTripper{
-		transport: transport,
+	requestTimeout := opts.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultRequestTimeout
 	}
+	streamConnectTimeout := opts.StreamConnectTimeout
+	if streamConnectTimeout <= 0 {
+		streamConnectTimeout = defaultStreamConnectTimeout
+	}
+
+	c := &Client{
+		host:                 host,
+		baseURL:              scheme + "://" + host,
+		tlsConfig:            tlsConfig,
+		guard:                newAuthGuard(opts.AuthMaxFailures, opts.AuthBackoff),
+		nonces:               &nonceCache{},
+		username:             username,
+		password:             password,
+		requestTimeout:       requestTimeout,
+		streamConnectTimeout: streamConnectTimeout,
+	}
+
+	c.streamPool = newStreamConnPool(c.dialStream)
 
-	return &Client{
-		host:     host,
-		username: username,
-		password: password,
-		client: &http.Client{
-			Transport: retryTransport,
-		},
+	// No client-wide timeout: audio and event streams stay open indefinitely.
+	// Requests are bounded by their context instead.
+	c.client = &http.Client{
+		Transport: c.newAuthTransport(&http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		}),
 	}
+
+	return c, nil
+}
+
+// requestContext bounds a single ISAPI request by the configured request timeout
+func (c *Client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.requestTimeout)
 }
 
-// loggingRoundTripper wraps digest.Transport to log auth attempts
+// url returns the absolute URL for an ISAPI path
+func (c *Client) url(path string) string {
+	return c.baseURL + path
+}
+
+// retryRoundTripper wraps digest.Transport to work around buggy auth challenges
+// and to stop sending requests while the credentials are being rejected
 type retryRoundTripper struct {
 	transport http.RoundTripper
+	guard     *authGuard
 }
 
 func (l *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := l.guard.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := l.roundTrip(req)
+	l.guard.record(resp, err)
+	return resp, err
+}
+
+func (l *retryRoundTripper) roundTrip(req *http.Request) (*http.Response, error) {
 	resp, err := l.transport.RoundTrip(req)
 
 	if err != nil {
@@ -107,18 +219,26 @@ func (l *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 }
 
 // GetTwoWayAudioChannels retrieves available two-way audio channels
-func (c *Client) GetTwoWayAudioChannels() (*TwoWayAudioChannelList, error) {
-	return c.getTwoWayAudioChannels(true)
+func (c *Client) GetTwoWayAudioChannels(ctx context.Context) (*TwoWayAudioChannelList, error) {
+	return c.getTwoWayAudioChannels(ctx, true)
 }
 
 // GetTwoWayAudioChannelsQuiet retrieves available two-way audio channels without logging (for health checks)
-func (c *Client) GetTwoWayAudioChannelsQuiet() (*TwoWayAudioChannelList, error) {
-	return c.getTwoWayAudioChannels(false)
+func (c *Client) GetTwoWayAudioChannelsQuiet(ctx context.Context) (*TwoWayAudioChannelList, error) {
+	return c.getTwoWayAudioChannels(ctx, false)
 }
 
-func (c *Client) getTwoWayAudioChannels(verbose bool) (*TwoWayAudioChannelList, error) {
-	url := fmt.Sprintf("http://%s/ISAPI/System/TwoWayAudio/channels", c.host)
-	resp, err := c.client.Get(url)
+func (c *Client) getTwoWayAudioChannels(ctx context.Context, verbose bool) (*TwoWayAudioChannelList, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	url := c.url("/ISAPI/System/TwoWayAudio/channels")
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
 	if err != nil {
 		if verbose {
 			log.Printf("[Hikvision] GetTwoWayAudioChannels: Request failed: %v", err)
@@ -132,7 +252,7 @@ func (c *Client) getTwoWayAudioChannels(verbose bool) (*TwoWayAudioChannelList,
 		if verbose {
 			log.Printf("[Hikvision] GetTwoWayAudioChannels: Error response body: %s", string(body))
 		}
-		return nil, fmt.Errorf("failed to get channels: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newError("GetTwoWayAudioChannels", resp.StatusCode, body)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -160,10 +280,16 @@ func (c *Client) getTwoWayAudioChannels(verbose bool) (*TwoWayAudioChannelList,
 }
 
 // OpenAudioChannel opens a two-way audio channel and returns the session
-func (c *Client) OpenAudioChannel(channelID string) (*AudioSession, error) {
-	url := fmt.Sprintf("http://%s/ISAPI/System/TwoWayAudio/channels/%s/open", c.host, channelID)
+func (c *Client) OpenAudioChannel(ctx context.Context, channelID string) (*AudioSession, error) {
+	// The audio stream writer follows, have its connection ready by then
+	c.WarmStreamConnection()
 
-	req, err := http.NewRequest("PUT", url, nil)
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	url := c.url(fmt.Sprintf("/ISAPI/System/TwoWayAudio/channels/%s/open", channelID))
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, nil)
 	if err != nil {
 		log.Printf("[Hikvision] OpenAudioChannel: Failed to create request: %v", err)
 		return nil, err
@@ -179,7 +305,7 @@ func (c *Client) OpenAudioChannel(channelID string) (*AudioSession, error) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("[Hikvision] OpenAudioChannel: Error response body: %s", string(body))
-		return nil, fmt.Errorf("failed to open channel: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, newError("OpenAudioChannel", resp.StatusCode, body)
 	}
 
 	// Parse the XML response to get the sessionId
@@ -202,11 +328,33 @@ func (c *Client) OpenAudioChannel(channelID string) (*AudioSession, error) {
 	}, nil
 }
 
+// SetAudioCompressionType switches the codec used by a two-way audio channel
+func (c *Client) SetAudioCompressionType(ctx context.Context, channelID, codec string) error {
+	path := "/ISAPI/System/TwoWayAudio/channels/" + channelID
+
+	// Read the current channel config so only the codec changes
+	var channel TwoWayAudioChannel
+	if err := c.getXML(ctx, "SetAudioCompressionType", path, &channel); err != nil {
+		return err
+	}
+	channel.AudioCompressionType = codec
+
+	if err := c.putXML(ctx, "SetAudioCompressionType", path, &channel); err != nil {
+		return err
+	}
+
+	log.Printf("[Hikvision] SetAudioCompressionType: Channel %s switched to %s", channelID, codec)
+	return nil
+}
+
 // CloseAudioChannel closes an active two-way audio session
-func (c *Client) CloseAudioChannel(channelID string) error {
-	url := fmt.Sprintf("http://%s/ISAPI/System/TwoWayAudio/channels/%s/close", c.host, channelID)
+func (c *Client) CloseAudioChannel(ctx context.Context, channelID string) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	url := c.url(fmt.Sprintf("/ISAPI/System/TwoWayAudio/channels/%s/close", channelID))
 
-	req, err := http.NewRequest("PUT", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, nil)
 	if err != nil {
 		log.Printf("[Hikvision] CloseAudioChannel: Failed to create request: %v", err)
 		return err
@@ -222,7 +370,7 @@ func (c *Client) CloseAudioChannel(channelID string) error {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("[Hikvision] CloseAudioChannel: Error response body: %s", string(body))
-		return fmt.Errorf("failed to close channel: status %d", resp.StatusCode)
+		return newError("CloseAudioChannel", resp.StatusCode, body)
 	}
 
 	log.Printf("[Hikvision] CloseAudioChannel: Channel %s closed successfully", channelID)
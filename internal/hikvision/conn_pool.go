@@ -0,0 +1,176 @@
+package hikvision
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// maxWarmConns is the number of connections kept ready for audio streams
+	maxWarmConns = 1
+
+	// warmConnMaxAge is how long a warm connection is kept before the device
+	// is likely to drop it for being idle
+	warmConnMaxAge = 30 * time.Second
+)
+
+// warmConn is an idle connection opened ahead of time
+type warmConn struct {
+	conn     net.Conn
+	dialTime time.Duration
+	expiry   *time.Timer
+}
+
+// streamConnPool keeps connections to the device open ahead of time, so a new
+// audio stream does not wait for the TCP (and TLS) handshake
+type streamConnPool struct {
+	dial func(ctx context.Context) (net.Conn, error)
+
+	mu      sync.Mutex
+	idle    []*warmConn
+	warming int // Dials in progress
+}
+
+func newStreamConnPool(dial func(ctx context.Context) (net.Conn, error)) *streamConnPool {
+	return &streamConnPool{dial: dial}
+}
+
+// warm opens a connection in the background unless enough are ready or being opened
+func (p *streamConnPool) warm(timeout time.Duration) {
+	p.mu.Lock()
+	if len(p.idle)+p.warming >= maxWarmConns {
+		p.mu.Unlock()
+		return
+	}
+	p.warming++
+	p.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		start := time.Now()
+		conn, err := p.dial(ctx)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.warming--
+		if err != nil {
+			log.Printf("[Hikvision] ConnPool: Failed to open warm connection: %v", err)
+			return
+		}
+
+		wc := &warmConn{conn: conn, dialTime: time.Since(start)}
+		wc.expiry = time.AfterFunc(warmConnMaxAge, func() { p.evict(wc) })
+		p.idle = append(p.idle, wc)
+	}()
+}
+
+// evict closes a warm connection that was not used in time
+func (p *streamConnPool) evict(wc *warmConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, c := range p.idle {
+		if c == wc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			wc.conn.Close()
+			return
+		}
+	}
+}
+
+// get returns a warm connection if one is still alive, or dials a new one.
+// The setup record of ctx, if any, notes which it was.
+func (p *streamConnPool) get(ctx context.Context) (net.Conn, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		wc := p.idle[0]
+		p.idle = p.idle[1:]
+		p.mu.Unlock()
+
+		wc.expiry.Stop()
+		if !alive(wc.conn) {
+			wc.conn.Close()
+			continue
+		}
+
+		if setup := streamSetupFrom(ctx); setup != nil {
+			setup.warmConn = true
+			setup.dialTime = wc.dialTime
+		}
+		return wc.conn, nil
+	}
+
+	return p.dial(ctx)
+}
+
+// alive reports whether the device has not closed an idle connection
+func alive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	// Nothing to read is what we expect; data or EOF means the connection is unusable
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// StreamSetupStats reports how audio stream connections were set up and the
+// latency saved by the warm connections and the cached digest nonce
+type StreamSetupStats struct {
+	Streams         int           // Audio streams set up
+	WarmConnections int           // Streams that used a connection opened ahead of time
+	CachedNonces    int           // Streams whose first request was accepted with the cached nonce
+	SavedLatency    time.Duration // Total handshake and challenge time kept off the critical path
+}
+
+// streamStats accumulates StreamSetupStats
+type streamStats struct {
+	mu    sync.Mutex
+	stats StreamSetupStats
+}
+
+// record adds a stream setup and returns the latency it saved
+func (s *streamStats) record(setup *streamSetup, challengeRTT time.Duration) time.Duration {
+	var saved time.Duration
+	if setup.warmConn {
+		saved += setup.dialTime
+	}
+	if !setup.challenged {
+		saved += challengeRTT
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Streams++
+	if setup.warmConn {
+		s.stats.WarmConnections++
+	}
+	if !setup.challenged {
+		s.stats.CachedNonces++
+	}
+	s.stats.SavedLatency += saved
+	return saved
+}
+
+// StreamSetupStats returns how audio stream connections were set up so far
+func (c *Client) StreamSetupStats() StreamSetupStats {
+	c.streamStats.mu.Lock()
+	defer c.streamStats.mu.Unlock()
+	return c.streamStats.stats
+}
+
+// WarmStreamConnection opens a connection for the next audio stream in the background
+func (c *Client) WarmStreamConnection() {
+	c.streamPool.warm(c.streamConnectTimeout)
+}
@@ -0,0 +1,215 @@
+package hikvision
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// DeviceInfo represents the device identity reported by /ISAPI/System/deviceInfo
+type DeviceInfo struct {
+	XMLName              xml.Name `xml:"DeviceInfo"`
+	DeviceName           string   `xml:"deviceName"`
+	DeviceID             string   `xml:"deviceID"`
+	Model                string   `xml:"model"`
+	SerialNumber         string   `xml:"serialNumber"`
+	MACAddress           string   `xml:"macAddress"`
+	FirmwareVersion      string   `xml:"firmwareVersion"`
+	FirmwareReleasedDate string   `xml:"firmwareReleasedDate"`
+	DeviceType           string   `xml:"deviceType"`
+}
+
+// OptionValue is an ISAPI capability element with its allowed values in the opt attribute
+type OptionValue struct {
+	Value string `xml:",chardata"`
+	Opt   string `xml:"opt,attr"`
+}
+
+// Options returns the allowed values, falling back to the current value
+func (o OptionValue) Options() []string {
+	if o.Opt == "" {
+		if o.Value == "" {
+			return nil
+		}
+		return []string{o.Value}
+	}
+	opts := strings.Split(o.Opt, ",")
+	for i := range opts {
+		opts[i] = strings.TrimSpace(opts[i])
+	}
+	return opts
+}
+
+// TwoWayAudioChannelCap represents the capabilities of a single two-way audio channel
+type TwoWayAudioChannelCap struct {
+	ID                   string      `xml:"id"`
+	AudioCompressionType OptionValue `xml:"audioCompressionType"`
+}
+
+// TwoWayAudioCapabilities represents /ISAPI/System/TwoWayAudio/channels/capabilities
+type TwoWayAudioCapabilities struct {
+	XMLName  xml.Name                `xml:"TwoWayAudioChannelList"`
+	Channels []TwoWayAudioChannelCap `xml:"TwoWayAudioChannel"`
+}
+
+// Codecs returns the union of codecs supported by all channels
+func (c *TwoWayAudioCapabilities) Codecs() []string {
+	seen := make(map[string]bool)
+	var codecs []string
+	for _, ch := range c.Channels {
+		for _, codec := range ch.AudioCompressionType.Options() {
+			if !seen[codec] {
+				seen[codec] = true
+				codecs = append(codecs, codec)
+			}
+		}
+	}
+	return codecs
+}
+
+// AudioCapabilities represents /ISAPI/System/Audio/capabilities
+type AudioCapabilities struct {
+	XMLName         xml.Name `xml:"AudioCap"`
+	AudioInputNums  int      `xml:"audioInputNums"`
+	AudioOutputNums int      `xml:"audioOutputNums"`
+}
+
+// getXML performs a GET request and decodes the XML response into v
+func (c *Client) getXML(ctx context.Context, op, path string, v any) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url(path), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] %s: Request failed: %v", op, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Hikvision] %s: Error response body: %s", op, string(body))
+		return newError(op, resp.StatusCode, body)
+	}
+
+	if err := xml.Unmarshal(body, v); err != nil {
+		log.Printf("[Hikvision] %s: Failed to parse XML: %v", op, err)
+		return err
+	}
+
+	return nil
+}
+
+// putXML encodes v as XML and sends it with a PUT request
+func (c *Client) putXML(ctx context.Context, op, path string, v any) error {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", c.url(path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] %s: Request failed: %v", op, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Printf("[Hikvision] %s: Error response body: %s", op, string(respBody))
+		return newError(op, resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+// postXML sends in as XML with a POST request and decodes the XML response into out
+func (c *Client) postXML(ctx context.Context, op, path string, in, out any) error {
+	body, err := xml.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url(path), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] %s: Request failed: %v", op, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[Hikvision] %s: Error response body: %s", op, string(respBody))
+		return newError(op, resp.StatusCode, respBody)
+	}
+
+	if err := xml.Unmarshal(respBody, out); err != nil {
+		log.Printf("[Hikvision] %s: Failed to parse XML: %v", op, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetDeviceInfo retrieves the device model, firmware and serial number
+func (c *Client) GetDeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	var info DeviceInfo
+	if err := c.getXML(ctx, "GetDeviceInfo", "/ISAPI/System/deviceInfo", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetTwoWayAudioCapabilities retrieves the codecs supported by the two-way audio channels
+func (c *Client) GetTwoWayAudioCapabilities(ctx context.Context) (*TwoWayAudioCapabilities, error) {
+	var caps TwoWayAudioCapabilities
+	if err := c.getXML(ctx, "GetTwoWayAudioCapabilities", "/ISAPI/System/TwoWayAudio/channels/capabilities", &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
+
+// GetAudioCapabilities retrieves the number of audio inputs and outputs
+func (c *Client) GetAudioCapabilities(ctx context.Context) (*AudioCapabilities, error) {
+	var caps AudioCapabilities
+	if err := c.getXML(ctx, "GetAudioCapabilities", "/ISAPI/System/Audio/capabilities", &caps); err != nil {
+		return nil, err
+	}
+	return &caps, nil
+}
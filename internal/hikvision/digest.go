@@ -0,0 +1,172 @@
+package hikvision
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/icholy/digest"
+)
+
+// nonceCache holds the last digest challenge of the device, shared by every
+// transport of a Client so a new stream can authenticate its first request
+// without a 401 round trip
+type nonceCache struct {
+	mu           sync.Mutex
+	chal         *digest.Challenge
+	count        int           // Nonce count of the last request signed with chal
+	challengeRTT time.Duration // Duration of the last 401 round trip, the latency a cached nonce saves
+}
+
+// next returns the cached challenge and the nonce count to sign the next request with
+func (n *nonceCache) next() (*digest.Challenge, int, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.chal == nil {
+		return nil, 0, false
+	}
+	n.count++
+	return n.chal, n.count, true
+}
+
+// save replaces the cached challenge after a 401
+func (n *nonceCache) save(chal *digest.Challenge, rtt time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.chal = chal
+	n.count = 0
+	n.challengeRTT = rtt
+}
+
+// savedLatency returns the latency of the challenge round trip a cached nonce avoids
+func (n *nonceCache) savedLatency() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.challengeRTT
+}
+
+// digestTransport signs requests with the cached nonce and falls back to the
+// usual challenge round trip when there is none or the device rejects it
+// (e.g. because the nonce went stale)
+type digestTransport struct {
+	transport   http.RoundTripper
+	nonces      *nonceCache
+	credentials func() (string, string)
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	getBody, err := rewindableBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := t.sign(req, getBody, false)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(first)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// A 401 without a challenge is left to retryRoundTripper
+	chal, err := digest.FindChallenge(resp.Header)
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	t.nonces.save(chal, time.Since(start))
+	if setup := streamSetupFrom(req.Context()); setup != nil {
+		setup.challenged = true
+	}
+
+	second, err := t.sign(req, getBody, true)
+	if err != nil {
+		return nil, err
+	}
+	return t.transport.RoundTrip(second)
+}
+
+// sign clones the request with a fresh body and the Authorization header for
+// the cached challenge, if any. required fails if there is no challenge.
+func (t *digestTransport) sign(req *http.Request, getBody func() (io.ReadCloser, error), required bool) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	body, err := getBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	clone.GetBody = getBody
+
+	chal, count, ok := t.nonces.next()
+	if !ok {
+		if required {
+			return nil, digest.ErrNoChallenge
+		}
+		return clone, nil
+	}
+
+	username, password := t.credentials()
+	cred, err := digest.Digest(chal, digest.Options{
+		Method:   req.Method,
+		URI:      req.URL.RequestURI(),
+		GetBody:  getBody,
+		Count:    count,
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clone.Header.Set("Authorization", cred.String())
+	return clone, nil
+}
+
+// rewindableBody returns a function that yields a fresh copy of the request
+// body, buffering it if the request cannot do that itself
+func rewindableBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.GetBody != nil {
+		return req.GetBody, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return func() (io.ReadCloser, error) { return http.NoBody, nil }, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}, nil
+}
+
+// streamSetup records how the connection of a stream request was set up
+type streamSetup struct {
+	warmConn   bool          // A pooled connection was used
+	dialTime   time.Duration // Time it took to open the pooled connection, off the critical path
+	challenged bool          // The request needed a 401 round trip
+}
+
+type streamSetupKey struct{}
+
+// withStreamSetup returns a context that records the setup of the request made with it
+func withStreamSetup(ctx context.Context) (context.Context, *streamSetup) {
+	setup := &streamSetup{}
+	return context.WithValue(ctx, streamSetupKey{}, setup), setup
+}
+
+// streamSetupFrom returns the setup record of a request context, if any
+func streamSetupFrom(ctx context.Context) *streamSetup {
+	setup, _ := ctx.Value(streamSetupKey{}).(*streamSetup)
+	return setup
+}
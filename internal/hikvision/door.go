@@ -0,0 +1,82 @@
+package hikvision
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// UnlockResult is the outcome of a remote door unlock request
+type UnlockResult string
+
+const (
+	UnlockResultSuccess      UnlockResult = "success"
+	UnlockResultLockedOut    UnlockResult = "locked_out"
+	UnlockResultNotSupported UnlockResult = "not_supported"
+)
+
+// RemoteControlDoor represents the XML body of a remote door command
+type RemoteControlDoor struct {
+	XMLName xml.Name `xml:"RemoteControlDoor"`
+	Version string   `xml:"version,attr"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Cmd     string   `xml:"cmd"`
+}
+
+// UnlockDoor asks the device to momentarily open the given door (electric strike)
+func (c *Client) UnlockDoor(ctx context.Context, doorID string) (UnlockResult, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	url := c.url(fmt.Sprintf("/ISAPI/AccessControl/RemoteControl/door/%s", doorID))
+
+	body, err := xml.Marshal(RemoteControlDoor{
+		Version: "2.0",
+		XMLNS:   "http://www.isapi.org/ver20/XMLSchema",
+		Cmd:     "open",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[Hikvision] UnlockDoor: Failed to create request: %v", err)
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] UnlockDoor: Request failed: %v", err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		log.Printf("[Hikvision] UnlockDoor: Door %s unlocked", doorID)
+		return UnlockResultSuccess, nil
+	}
+
+	log.Printf("[Hikvision] UnlockDoor: Error response body: %s", string(respBody))
+
+	err = newError("UnlockDoor", resp.StatusCode, respBody)
+	switch {
+	case errors.Is(err, ErrNotSupported):
+		return UnlockResultNotSupported, nil
+	case errors.Is(err, ErrDeviceLocked):
+		return UnlockResultLockedOut, nil
+	}
+
+	return "", err
+}
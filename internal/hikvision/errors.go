@@ -0,0 +1,117 @@
+package hikvision
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnauthorized is returned when the device rejects the credentials
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrChannelBusy is returned when the device is busy, e.g. the indoor station holds the audio channel
+	ErrChannelBusy = errors.New("channel busy")
+
+	// ErrNotSupported is returned when the device does not support the requested operation
+	ErrNotSupported = errors.New("not supported")
+
+	// ErrDeviceLocked is returned when the device, user account or door is locked
+	ErrDeviceLocked = errors.New("device locked")
+
+	// ErrInvalidSession is returned when the audio session is unknown or has expired
+	ErrInvalidSession = errors.New("invalid session")
+
+	// ErrAuthLockedOut is returned without contacting the device while requests
+	// are suspended after repeated authentication failures
+	ErrAuthLockedOut = errors.New("authentication locked out")
+)
+
+// statusCodeDeviceBusy is the ResponseStatus statusCode for "Device Busy"
+const statusCodeDeviceBusy = 2
+
+// Error is a failed ISAPI request. It wraps one of the Err* sentinels when the
+// failure could be classified, so callers can use errors.Is.
+type Error struct {
+	Op         string          // Operation that failed, e.g. "OpenAudioChannel"
+	HTTPStatus int             // HTTP status code of the response
+	Status     *ResponseStatus // Decoded ResponseStatus, if the body contained one
+	Body       string          // Raw response body
+	kind       error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s failed: status %d", e.Op, e.HTTPStatus)
+	if e.Status != nil {
+		msg += fmt.Sprintf(" (%s", e.Status.StatusString)
+		if e.Status.SubStatusCode != "" {
+			msg += ": " + e.Status.SubStatusCode
+		}
+		msg += ")"
+	} else if e.Body != "" {
+		msg += ", body: " + e.Body
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// newError builds an Error from a non-OK response and classifies it
+func newError(op string, httpStatus int, body []byte) error {
+	e := &Error{
+		Op:         op,
+		HTTPStatus: httpStatus,
+		Body:       strings.TrimSpace(string(body)),
+	}
+
+	var status ResponseStatus
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		if err := json.Unmarshal(body, &status); err == nil && status.StatusString != "" {
+			e.Status = &status
+		}
+	} else if err := xml.Unmarshal(body, &status); err == nil {
+		e.Status = &status
+	}
+
+	e.kind = classifyError(httpStatus, e.Status, e.Body)
+	return e
+}
+
+// classifyError maps an HTTP status and ISAPI ResponseStatus to a sentinel error
+func classifyError(httpStatus int, status *ResponseStatus, body string) error {
+	// Locked accounts are reported with a 401/403 and a lockStatus element
+	if strings.Contains(body, "<lockStatus>lock</lockStatus>") {
+		return ErrDeviceLocked
+	}
+
+	if status != nil {
+		sub := strings.ToLower(status.SubStatusCode)
+		switch {
+		case strings.Contains(sub, "lock"), sub == "alwaysclose":
+			return ErrDeviceLocked
+		case strings.Contains(sub, "session"):
+			return ErrInvalidSession
+		case strings.Contains(sub, "busy"), status.StatusCode == statusCodeDeviceBusy:
+			return ErrChannelBusy
+		case sub == "notsupport", sub == "invalidoperation":
+			return ErrNotSupported
+		case sub == "lowprivilege", sub == "badauthorization":
+			return ErrUnauthorized
+		}
+	}
+
+	switch httpStatus {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound, http.StatusNotImplemented, http.StatusMethodNotAllowed:
+		return ErrNotSupported
+	}
+
+	return nil
+}
@@ -0,0 +1,288 @@
+package hikvision
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventKind is the normalized category of a device event
+type EventKind string
+
+const (
+	EventKindDoorbell EventKind = "doorbell"
+	EventKindMotion   EventKind = "motion"
+	EventKindTamper   EventKind = "tamper"
+	EventKindOther    EventKind = "other"
+)
+
+// eventKinds maps lowercase ISAPI eventType values to their normalized kind
+var eventKinds = map[string]EventKind{
+	"doorbell":           EventKindDoorbell,
+	"doorbellring":       EventKindDoorbell,
+	"callevent":          EventKindDoorbell,
+	"videointercomevent": EventKindDoorbell,
+	"vmd":                EventKindMotion,
+	"motiondetection":    EventKindMotion,
+	"pir":                EventKindMotion,
+	"pirmotion":          EventKindMotion,
+	"shelteralarm":       EventKindTamper,
+	"tamperdetection":    EventKindTamper,
+	"tamper":             EventKindTamper,
+}
+
+// EventNotificationAlert represents a single XML block from the alert stream
+type EventNotificationAlert struct {
+	XMLName          xml.Name `xml:"EventNotificationAlert"`
+	IPAddress        string   `xml:"ipAddress"`
+	ChannelID        string   `xml:"channelID"`
+	DateTime         string   `xml:"dateTime"`
+	ActivePostCount  int      `xml:"activePostCount"`
+	EventType        string   `xml:"eventType"`
+	EventState       string   `xml:"eventState"`
+	EventDescription string   `xml:"eventDescription"`
+}
+
+// eventNotificationJSON is the JSON flavour of EventNotificationAlert sent by newer firmware
+type eventNotificationJSON struct {
+	IPAddress        string `json:"ipAddress"`
+	ChannelID        any    `json:"channelID"`
+	DateTime         string `json:"dateTime"`
+	ActivePostCount  int    `json:"activePostCount"`
+	EventType        string `json:"eventType"`
+	EventState       string `json:"eventState"`
+	EventDescription string `json:"eventDescription"`
+}
+
+// Event is a parsed and classified device event
+type Event struct {
+	Kind        EventKind
+	EventType   string
+	State       string
+	ChannelID   string
+	Description string
+	Time        time.Time
+}
+
+// eventStreamIdleTimeout is how long the alert stream may stay silent. Devices
+// send a heartbeat every 10 seconds, so a longer silence means the connection
+// is half-open.
+const eventStreamIdleTimeout = 30 * time.Second
+
+// EventStreamReader holds the long-lived alertStream connection and emits parsed events
+type EventStreamReader struct {
+	client      *Client
+	url         string
+	retryDelay  time.Duration
+	idleTimeout time.Duration // Reconnect when no part arrives for this long
+	eventChan   chan Event
+	ctx         context.Context
+	cancel      context.CancelFunc // Cancels the streaming request
+	closeOnce   sync.Once
+	wg          sync.WaitGroup // Wait for streamLoop to complete
+}
+
+// NewEventStreamReader creates a new alert stream reader
+func (c *Client) NewEventStreamReader() *EventStreamReader {
+	url := c.url("/ISAPI/Event/notification/alertStream")
+
+	return &EventStreamReader{
+		client:      c,
+		url:         url,
+		retryDelay:  5 * time.Second,
+		idleTimeout: eventStreamIdleTimeout,
+		eventChan:   make(chan Event, 32),
+	}
+}
+
+// Events returns the channel on which parsed events are delivered
+func (e *EventStreamReader) Events() <-chan Event {
+	return e.eventChan
+}
+
+// Start begins reading the alert stream, reconnecting whenever it drops.
+// The stream ends when ctx is cancelled or Close is called.
+func (e *EventStreamReader) Start(ctx context.Context) {
+	log.Printf("[Hikvision] EventStreamReader: Starting alert stream")
+	e.ctx, e.cancel = context.WithCancel(ctx)
+	e.wg.Add(1)
+	go e.streamLoop()
+}
+
+// streamLoop keeps the alert stream connected until Close is called
+func (e *EventStreamReader) streamLoop() {
+	defer e.wg.Done()
+	defer close(e.eventChan)
+
+	for {
+		err := e.readStream()
+		if e.ctx.Err() != nil {
+			log.Printf("[Hikvision] EventStreamReader: Stopped")
+			return
+		}
+		log.Printf("[Hikvision] EventStreamReader: Stream interrupted: %v, reconnecting in %s", err, e.retryDelay)
+
+		select {
+		case <-e.ctx.Done():
+			log.Printf("[Hikvision] EventStreamReader: Stopped")
+			return
+		case <-time.After(e.retryDelay):
+		}
+	}
+}
+
+// readStream opens a single alertStream connection and reads parts until it fails
+func (e *EventStreamReader) readStream() error {
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", e.url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Abort the request if the device does not answer in time
+	connectTimer := time.AfterFunc(e.client.streamConnectTimeout, cancel)
+	resp, err := e.client.client.Do(req)
+	connectTimer.Stop()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return newError("EventStreamReader", resp.StatusCode, body)
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid alert stream content type: %w", err)
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return fmt.Errorf("alert stream has no multipart boundary")
+	}
+
+	log.Printf("[Hikvision] EventStreamReader: Connected, waiting for events...")
+
+	// Cancelling the request closes the body, so a stream that goes silent
+	// fails its pending read and reconnects instead of blocking forever
+	var idle atomic.Bool
+	idleTimer := time.AfterFunc(e.idleTimeout, func() {
+		idle.Store(true)
+		cancel()
+	})
+	defer idleTimer.Stop()
+
+	reader := multipart.NewReader(resp.Body, strings.TrimPrefix(boundary, "--"))
+	readErr := func(err error) error {
+		if idle.Load() {
+			return fmt.Errorf("no data on the alert stream for %s", e.idleTimeout)
+		}
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return readErr(err)
+		}
+
+		body, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return readErr(err)
+		}
+		idleTimer.Reset(e.idleTimeout)
+
+		event, ok := parseEvent(part.Header.Get("Content-Type"), body)
+		if !ok {
+			continue
+		}
+
+		select {
+		case e.eventChan <- event:
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		}
+	}
+}
+
+// parseEvent decodes an XML or JSON alert block, skipping idle heartbeats and binary parts
+func parseEvent(contentType string, body []byte) (Event, bool) {
+	var alert EventNotificationAlert
+
+	switch {
+	case strings.Contains(contentType, "json"):
+		var j eventNotificationJSON
+		if err := json.Unmarshal(body, &j); err != nil {
+			log.Printf("[Hikvision] EventStreamReader: Failed to parse JSON event: %v", err)
+			return Event{}, false
+		}
+		alert = EventNotificationAlert{
+			IPAddress:        j.IPAddress,
+			DateTime:         j.DateTime,
+			ActivePostCount:  j.ActivePostCount,
+			EventType:        j.EventType,
+			EventState:       j.EventState,
+			EventDescription: j.EventDescription,
+		}
+		if j.ChannelID != nil {
+			alert.ChannelID = fmt.Sprint(j.ChannelID)
+		}
+	case strings.Contains(contentType, "xml"):
+		if err := xml.Unmarshal(body, &alert); err != nil {
+			log.Printf("[Hikvision] EventStreamReader: Failed to parse XML event: %v", err)
+			return Event{}, false
+		}
+	default:
+		// Picture attachments and other binary parts
+		return Event{}, false
+	}
+
+	// The device sends "videoloss" inactive blocks as a heartbeat
+	if strings.EqualFold(alert.EventType, "videoloss") && strings.EqualFold(alert.EventState, "inactive") {
+		return Event{}, false
+	}
+
+	kind, ok := eventKinds[strings.ToLower(alert.EventType)]
+	if !ok {
+		kind = EventKindOther
+	}
+
+	eventTime, err := time.Parse(time.RFC3339, alert.DateTime)
+	if err != nil {
+		eventTime = time.Now()
+	}
+
+	return Event{
+		Kind:        kind,
+		EventType:   alert.EventType,
+		State:       alert.EventState,
+		ChannelID:   alert.ChannelID,
+		Description: alert.EventDescription,
+		Time:        eventTime,
+	}, true
+}
+
+// Close stops the alert stream and waits for cleanup to complete
+func (e *EventStreamReader) Close() error {
+	e.closeOnce.Do(func() {
+		if e.cancel != nil {
+			e.cancel()
+		}
+		e.wg.Wait() // Wait for streamLoop to complete cleanup
+		log.Printf("[Hikvision] EventStreamReader: Cleanup complete")
+	})
+	return nil
+}
@@ -0,0 +1,134 @@
+package hikvision
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	defaultReconnectAttempts = 3
+	defaultReconnectDelay    = 500 * time.Millisecond
+)
+
+// ReconnectState is the progress of an audio stream reconnect
+type ReconnectState string
+
+const (
+	ReconnectStateReconnecting ReconnectState = "reconnecting"
+	ReconnectStateReconnected  ReconnectState = "reconnected"
+	ReconnectStateFailed       ReconnectState = "failed"
+)
+
+// ReconnectEvent reports the reconnect of a dropped audio stream
+type ReconnectEvent struct {
+	Stream    string // "reader" (device microphone) or "writer" (device speaker)
+	ChannelID string
+	State     ReconnectState
+	Attempt   int   // 1 for the first attempt
+	Err       error // Why the stream dropped or the attempt failed (nil once reconnected)
+}
+
+// ReconnectPolicy configures how an audio stream recovers from a dropped connection.
+// The zero value retries with the defaults and never re-opens the channel.
+type ReconnectPolicy struct {
+	// MaxAttempts bounds the reconnect attempts after a drop (default 3, negative disables reconnects)
+	MaxAttempts int
+
+	// Delay is the pause before the first attempt, doubling with each further one (default 500ms)
+	Delay time.Duration
+
+	// Reopen re-opens the two-way audio channel, e.g. through the session manager,
+	// for when the device dropped the session along with the connection.
+	// It is tried from the second attempt on. Optional.
+	Reopen func(ctx context.Context) (*AudioSession, error)
+
+	// OnEvent is called as the reconnect progresses. Optional.
+	OnEvent func(ReconnectEvent)
+}
+
+// reconnector runs the reconnect attempts of one stream
+type reconnector struct {
+	policy    ReconnectPolicy
+	stream    string
+	channelID string
+	attempt   int // Attempts since the stream last dropped, 0 while connected
+}
+
+func newReconnector(policy ReconnectPolicy, stream, channelID string) *reconnector {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = defaultReconnectAttempts
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = defaultReconnectDelay
+	}
+	return &reconnector{policy: policy, stream: stream, channelID: channelID}
+}
+
+// retry decides whether to try again after err dropped the stream or failed an
+// attempt. It waits for the backoff and re-opens the channel when due; it returns
+// false once the attempts are exhausted, the error is not worth retrying, or
+// stop or ctx end the stream.
+func (r *reconnector) retry(ctx context.Context, stop <-chan struct{}, err error) (*AudioSession, bool) {
+	// Retrying bad credentials would get the device account locked
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrAuthLockedOut) || errors.Is(err, ErrDeviceLocked) ||
+		r.attempt >= r.policy.MaxAttempts {
+		r.emit(ReconnectStateFailed, err)
+		return nil, false
+	}
+
+	r.attempt++
+	r.emit(ReconnectStateReconnecting, err)
+
+	select {
+	case <-time.After(r.policy.Delay << (r.attempt - 1)):
+	case <-stop:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+
+	if r.attempt < 2 || r.policy.Reopen == nil {
+		return nil, true
+	}
+	session, err := r.policy.Reopen(ctx)
+	if err != nil {
+		log.Printf("[Hikvision] %s: Failed to re-open channel %s: %v", r.name(), r.channelID, err)
+		return nil, true
+	}
+	return session, true
+}
+
+// connected resets the attempts once the stream is back
+func (r *reconnector) connected() {
+	if r.attempt > 0 {
+		r.emit(ReconnectStateReconnected, nil)
+		r.attempt = 0
+	}
+}
+
+func (r *reconnector) emit(state ReconnectState, err error) {
+	if err != nil {
+		log.Printf("[Hikvision] %s: Stream %s (attempt %d/%d): %v", r.name(), state, r.attempt, r.policy.MaxAttempts, err)
+	} else {
+		log.Printf("[Hikvision] %s: Stream %s", r.name(), state)
+	}
+
+	if r.policy.OnEvent != nil {
+		r.policy.OnEvent(ReconnectEvent{
+			Stream:    r.stream,
+			ChannelID: r.channelID,
+			State:     state,
+			Attempt:   r.attempt,
+			Err:       err,
+		})
+	}
+}
+
+func (r *reconnector) name() string {
+	if r.stream == "reader" {
+		return "AudioStreamReader"
+	}
+	return "AudioStreamWriter"
+}
@@ -0,0 +1,260 @@
+package hikvision
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultRecordingTrack is the recording track of the main stream of the first camera
+const DefaultRecordingTrack = "101"
+
+const (
+	// recordingSearchPageSize is the number of matches requested per search call
+	recordingSearchPageSize = 50
+
+	// maxRecordingResults bounds a search, the device has no total count for open-ended ones
+	maxRecordingResults = 1000
+
+	// recordingTimeLayout is the ISAPI timestamp format
+	recordingTimeLayout = "2006-01-02T15:04:05Z"
+)
+
+// RecordingType is what triggered a recording
+type RecordingType string
+
+const (
+	RecordingTypeAll        RecordingType = "all"
+	RecordingTypeContinuous RecordingType = "continuous"
+	RecordingTypeMotion     RecordingType = "motion"
+	RecordingTypeAlarm      RecordingType = "alarm"
+	RecordingTypeEvent      RecordingType = "event"
+)
+
+// recordingTypeMetadata maps recording types to the ISAPI metadata descriptors
+var recordingTypeMetadata = map[RecordingType]string{
+	RecordingTypeAll:        "//recordType.meta.std-cgi.com",
+	RecordingTypeContinuous: "//recordType.meta.std-cgi.com/CMR",
+	RecordingTypeMotion:     "//recordType.meta.std-cgi.com/VMD",
+	RecordingTypeAlarm:      "//recordType.meta.std-cgi.com/ALARM",
+	RecordingTypeEvent:      "//recordType.meta.std-cgi.com/allEvent",
+}
+
+// ParseRecordingType returns the recording type with the given name
+func ParseRecordingType(name string) (RecordingType, bool) {
+	t := RecordingType(name)
+	_, ok := recordingTypeMetadata[t]
+	return t, ok
+}
+
+// RecordingSearch selects the recordings returned by SearchRecordings
+type RecordingSearch struct {
+	TrackID string
+	From    time.Time
+	To      time.Time
+	Type    RecordingType
+}
+
+// Recording is a clip stored on the device
+type Recording struct {
+	TrackID     string        `json:"track_id"`
+	Start       time.Time     `json:"start"`
+	End         time.Time     `json:"end"`
+	Type        RecordingType `json:"type,omitempty"`
+	ContentType string        `json:"content_type,omitempty"`
+	Codec       string        `json:"codec,omitempty"`
+	PlaybackURI string        `json:"playback_uri"`
+}
+
+// cmSearchDescription is the body of POST /ISAPI/ContentMgmt/search
+type cmSearchDescription struct {
+	XMLName        xml.Name `xml:"CMSearchDescription"`
+	SearchID       string   `xml:"searchID"`
+	TrackIDs       []string `xml:"trackList>trackID"`
+	StartTime      string   `xml:"timeSpanList>timeSpan>startTime"`
+	EndTime        string   `xml:"timeSpanList>timeSpan>endTime"`
+	MaxResults     int      `xml:"maxResults"`
+	SearchPosition int      `xml:"searchResultPostion"` // Sic, the device's spelling
+	Metadata       []string `xml:"metadataList>metadataDescriptor"`
+}
+
+// cmSearchResult is the response of POST /ISAPI/ContentMgmt/search
+type cmSearchResult struct {
+	XMLName      xml.Name `xml:"CMSearchResult"`
+	SearchID     string   `xml:"searchID"`
+	Status       string   `xml:"responseStatusStrg"` // "OK", "MORE" or "NO MATCHES"
+	NumOfMatches int      `xml:"numOfMatches"`
+	Matches      []struct {
+		TrackID   string `xml:"trackID"`
+		StartTime string `xml:"timeSpan>startTime"`
+		EndTime   string `xml:"timeSpan>endTime"`
+		Media     struct {
+			ContentType string `xml:"contentType"`
+			CodecType   string `xml:"codecType"`
+			PlaybackURI string `xml:"playbackURI"`
+		} `xml:"mediaSegmentDescriptor"`
+		Metadata string `xml:"metadataMatches>metadataDescriptor"`
+	} `xml:"matchList>searchMatchItem"`
+}
+
+// SearchRecordings lists the recordings stored on the device in a time range
+func (c *Client) SearchRecordings(ctx context.Context, search RecordingSearch) ([]Recording, error) {
+	if search.TrackID == "" {
+		search.TrackID = DefaultRecordingTrack
+	}
+	if search.Type == "" {
+		search.Type = RecordingTypeAll
+	}
+	metadata, ok := recordingTypeMetadata[search.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown recording type %q", search.Type)
+	}
+
+	// The search ID lets the device page through the same result set
+	searchID, err := newSearchID()
+	if err != nil {
+		return nil, err
+	}
+
+	recordings := []Recording{}
+	for len(recordings) < maxRecordingResults {
+		var result cmSearchResult
+		if err := c.postXML(ctx, "SearchRecordings", "/ISAPI/ContentMgmt/search", cmSearchDescription{
+			SearchID:       searchID,
+			TrackIDs:       []string{search.TrackID},
+			StartTime:      search.From.UTC().Format(recordingTimeLayout),
+			EndTime:        search.To.UTC().Format(recordingTimeLayout),
+			MaxResults:     recordingSearchPageSize,
+			SearchPosition: len(recordings),
+			Metadata:       []string{metadata},
+		}, &result); err != nil {
+			return nil, err
+		}
+
+		for _, m := range result.Matches {
+			recordings = append(recordings, Recording{
+				TrackID:     m.TrackID,
+				Start:       parseRecordingTime(m.StartTime),
+				End:         parseRecordingTime(m.EndTime),
+				Type:        recordingTypeFromMetadata(m.Metadata),
+				ContentType: m.Media.ContentType,
+				Codec:       m.Media.CodecType,
+				PlaybackURI: m.Media.PlaybackURI,
+			})
+		}
+
+		if result.Status != "MORE" || len(result.Matches) == 0 {
+			break
+		}
+	}
+
+	log.Printf("[Hikvision] SearchRecordings: Found %d %s recordings on track %s", len(recordings), search.Type, search.TrackID)
+	return recordings, nil
+}
+
+// RecordingDownload is the content of a recording being downloaded
+type RecordingDownload struct {
+	Body          io.ReadCloser
+	ContentType   string
+	ContentLength int64 // -1 if unknown
+}
+
+// DownloadRecording starts downloading the recording with the given playback URI,
+// as returned by SearchRecordings. The caller must close the body.
+func (c *Client) DownloadRecording(ctx context.Context, playbackURI string) (*RecordingDownload, error) {
+	body, err := xml.Marshal(struct {
+		XMLName     xml.Name `xml:"downloadRequest"`
+		PlaybackURI string   `xml:"playbackURI"`
+	}{PlaybackURI: playbackURI})
+	if err != nil {
+		return nil, err
+	}
+
+	// No request timeout: clips take a while to transfer
+	ctx, cancel := context.WithCancel(ctx)
+
+	// The device expects the request in the body of a GET
+	req, err := http.NewRequestWithContext(ctx, "GET", c.url("/ISAPI/ContentMgmt/download"), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml")
+
+	// Abort the request if the device does not answer in time
+	connectTimer := time.AfterFunc(c.streamConnectTimeout, cancel)
+	resp, err := c.client.Do(req)
+	connectTimer.Stop()
+	if err != nil {
+		cancel()
+		log.Printf("[Hikvision] DownloadRecording: Request failed: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Printf("[Hikvision] DownloadRecording: Error response body: %s", string(respBody))
+		return nil, newError("DownloadRecording", resp.StatusCode, respBody)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "video/mp4"
+	}
+
+	return &RecordingDownload{
+		Body:          &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel},
+		ContentType:   contentType,
+		ContentLength: resp.ContentLength,
+	}, nil
+}
+
+// cancelReadCloser releases the request context when the body is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelReadCloser) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// newSearchID returns a random UUID, as expected in searchID
+func newSearchID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// parseRecordingTime parses an ISAPI timestamp, which some firmware sends without the zone
+func parseRecordingTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02T15:04:05", s)
+	return t
+}
+
+// recordingTypeFromMetadata returns the recording type of a metadata descriptor, if known
+func recordingTypeFromMetadata(metadata string) RecordingType {
+	for t, descriptor := range recordingTypeMetadata {
+		if t != RecordingTypeAll && strings.EqualFold(descriptor, metadata) {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package hikvision
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// DefaultSnapshotChannel is the main stream of the first camera
+const DefaultSnapshotChannel = "101"
+
+// Snapshot is a still image captured from the camera
+type Snapshot struct {
+	ContentType string
+	Data        []byte
+}
+
+// GetSnapshot fetches a still image from the given streaming channel
+func (c *Client) GetSnapshot(ctx context.Context, channelID string) (*Snapshot, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+
+	url := c.url(fmt.Sprintf("/ISAPI/Streaming/channels/%s/picture", channelID))
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Printf("[Hikvision] GetSnapshot: Request failed: %v", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("[Hikvision] GetSnapshot: Error response body: %s", string(body))
+		return nil, newError("GetSnapshot", resp.StatusCode, body)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+
+	return &Snapshot{
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
@@ -1,83 +1,138 @@
 package hikvision
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // AudioStreamReader continuously reads audio data from the device
 type AudioStreamReader struct {
 	client      *Client
 	session     *AudioSession
-	url         string
 	stopChan    chan struct{}
 	dataChan    chan []byte
 	errChan     chan error
 	closeOnce   sync.Once
-	buffer      []byte // Buffer for partial reads
+	cancel      context.CancelFunc // Cancels the streaming request
+	buffer      []byte             // Buffer for partial reads
 	bufferMutex sync.Mutex
 	wg          sync.WaitGroup // Wait for streamLoop to complete
+	reconnect   ReconnectPolicy
 }
 
 // NewAudioStreamReader creates a new continuous audio stream reader
 func (c *Client) NewAudioStreamReader(session *AudioSession) *AudioStreamReader {
-	url := fmt.Sprintf("http://%s/ISAPI/System/TwoWayAudio/channels/%s/audioData", c.host, session.ChannelID)
-	if session.SessionID != "" {
-		url += "?sessionId=" + session.SessionID
-	}
-
 	return &AudioStreamReader{
 		client:   c,
 		session:  session,
-		url:      url,
 		stopChan: make(chan struct{}),
 		dataChan: make(chan []byte, 128),
 		errChan:  make(chan error, 1),
 	}
 }
 
-// Start begins the continuous streaming
-func (a *AudioStreamReader) Start() {
+// SetReconnect configures how the stream recovers from a dropped connection. Call it before Start.
+func (a *AudioStreamReader) SetReconnect(policy ReconnectPolicy) {
+	a.reconnect = policy
+}
+
+// Start begins the continuous streaming. The stream ends when ctx is cancelled or Close is called.
+func (a *AudioStreamReader) Start(ctx context.Context) {
 	log.Printf("[Hikvision] AudioStreamReader: Starting stream for channel %s", a.session.ChannelID)
+	ctx, a.cancel = context.WithCancel(ctx)
 	a.wg.Add(1)
-	go a.streamLoop()
+	go a.streamLoop(ctx)
 }
 
-// streamLoop continuously reads audio data from a single persistent connection
-func (a *AudioStreamReader) streamLoop() {
+// streamLoop reads audio data from a persistent connection, reconnecting if it drops
+func (a *AudioStreamReader) streamLoop(ctx context.Context) {
 	defer a.wg.Done()
 
+	session := *a.session
+	reconnector := newReconnector(a.reconnect, "reader", session.ChannelID)
+	connected := false
+
+	for {
+		err := a.stream(ctx, session, func() {
+			connected = true
+			reconnector.connected()
+		})
+
+		select {
+		case <-a.stopChan:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// Failing to connect in the first place is reported as is
+		if !connected {
+			a.errChan <- err
+			return
+		}
+
+		reopened, ok := reconnector.retry(ctx, a.stopChan, err)
+		if !ok {
+			select {
+			case <-a.stopChan:
+			case <-ctx.Done():
+			default:
+				a.errChan <- err
+			}
+			return
+		}
+		if reopened != nil {
+			session = *reopened
+		}
+	}
+}
+
+// stream reads from a single connection until it drops, calling onConnect once established
+func (a *AudioStreamReader) stream(ctx context.Context, session AudioSession, onConnect func()) error {
+	url := a.client.url(fmt.Sprintf("/ISAPI/System/TwoWayAudio/channels/%s/audioData", session.ChannelID))
+	if session.SessionID != "" {
+		url += "?sessionId=" + session.SessionID
+	}
+
+	// Each connection gets its own context so the connect timeout does not end the stream
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	// Make a single GET request that stays open
-	req, err := http.NewRequest("GET", a.url, nil)
+	req, err := http.NewRequestWithContext(connCtx, "GET", url, nil)
 	if err != nil {
 		log.Printf("[Hikvision] AudioStreamReader: Failed to create request: %v", err)
-		a.errChan <- err
-		return
+		return err
 	}
 
 	// Set headers like go2rtc does
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("Content-Length", "0")
 
+	// Abort the request if the device does not answer in time
+	connectTimer := time.AfterFunc(a.client.streamConnectTimeout, cancel)
 	resp, err := a.client.client.Do(req)
+	connectTimer.Stop()
 	if err != nil {
 		log.Printf("[Hikvision] AudioStreamReader: Request failed: %v", err)
-		a.errChan <- err
-		return
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("[Hikvision] AudioStreamReader: Error status %d, body: %s", resp.StatusCode, string(body))
-		a.errChan <- fmt.Errorf("failed to get audio data: status %d, body: %s", resp.StatusCode, string(body))
-		return
+		return newError("AudioStreamReader", resp.StatusCode, body)
 	}
 
 	log.Printf("[Hikvision] AudioStreamReader: Connected, streaming audio data...")
+	onConnect()
 
 	// Continuously read from the persistent connection
 	buffer := make([]byte, 8192)
@@ -87,7 +142,7 @@ func (a *AudioStreamReader) streamLoop() {
 		select {
 		case <-a.stopChan:
 			log.Printf("[Hikvision] AudioStreamReader: Stopped after %d chunks", chunkCount)
-			return
+			return nil
 		default:
 			n, err := resp.Body.Read(buffer)
 			if n > 0 {
@@ -103,7 +158,7 @@ func (a *AudioStreamReader) streamLoop() {
 					}
 				case <-a.stopChan:
 					log.Printf("[Hikvision] AudioStreamReader: Stopped while sending chunk %d", chunkCount)
-					return
+					return nil
 				}
 			}
 
@@ -112,9 +167,8 @@ func (a *AudioStreamReader) streamLoop() {
 					log.Printf("[Hikvision] AudioStreamReader: Stream ended (EOF) after %d chunks", chunkCount)
 				} else {
 					log.Printf("[Hikvision] AudioStreamReader: Read error after %d chunks: %v", chunkCount, err)
-					a.errChan <- err
 				}
-				return
+				return err
 			}
 		}
 	}
@@ -152,6 +206,9 @@ func (a *AudioStreamReader) Read(p []byte) (int, error) {
 func (a *AudioStreamReader) Close() error {
 	a.closeOnce.Do(func() {
 		close(a.stopChan)
+		if a.cancel != nil {
+			a.cancel() // Unblock a pending read on the connection
+		}
 		a.wg.Wait() // Wait for streamLoop to complete cleanup
 		log.Printf("[Hikvision] AudioStreamReader: Cleanup complete for channel %s", a.session.ChannelID)
 	})
@@ -8,80 +8,157 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/icholy/digest"
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 )
 
+const (
+	// playoutLead is how far ahead of its playout time a frame is written
+	playoutLead = 2 * audio.SampleDuration
+
+	// maxFrameLateness is how far the writer may fall behind the timeline
+	// before it restarts the timeline instead of catching up
+	maxFrameLateness = 3 * audio.SampleDuration
+)
+
+// AudioStreamWriterStats reports how the writer paced the audio
+type AudioStreamWriterStats struct {
+	Frames             int           // 20 ms audio frames sent
+	SilenceFrames      int           // Silence frames sent to fill underruns
+	Underruns          int           // Frame slots with no audio to send
+	Resyncs            int           // Times the timeline was restarted after falling behind
+	DroppedBytes       int           // Audio dropped to stay under the maximum latency
+	BufferedLatency    time.Duration // Audio waiting to be sent
+	MaxBufferedLatency time.Duration // Highest BufferedLatency so far
+}
+
 // AudioStreamWriter continuously sends audio data to the device
 type AudioStreamWriter struct {
-	client    *Client
-	session   *AudioSession
-	url       string
-	stopChan  chan struct{}
-	dataChan  chan []byte
-	errChan   chan error
-	closeOnce sync.Once
-	wg        sync.WaitGroup // Wait for sendLoop to complete
+	client     *Client
+	session    *AudioSession
+	url        string
+	stopChan   chan struct{}
+	dataChan   chan []byte
+	flushChan  chan chan struct{}
+	loopDone   chan struct{} // Closed when sendLoop returns
+	err        error         // Why sendLoop returned, set before loopDone is closed (nil when closed)
+	closeOnce  sync.Once
+	cancel     context.CancelFunc // Cancels the streaming request
+	wg         sync.WaitGroup     // Wait for sendLoop to complete
+	maxLatency time.Duration      // Buffered audio beyond this is dropped (0 = never)
+	queued     atomic.Int64       // Bytes in dataChan
+	frameSize  int                // Bytes in a 20 ms frame of the codec
+	silence    []byte             // Frame sent when there is no audio (nil = send nothing)
+	reconnect  ReconnectPolicy
+
+	// Owned by sendLoop, kept across reconnects
+	pending []byte          // Audio taken from dataChan, not yet sent
+	flushes []chan struct{} // Flush calls waiting for the buffer to empty
+
+	statsMu sync.Mutex
+	stats   AudioStreamWriterStats
 }
 
 // NewAudioStreamWriter creates a new continuous audio stream writer
 func (c *Client) NewAudioStreamWriter(session *AudioSession) *AudioStreamWriter {
-	url := fmt.Sprintf("http://%s/ISAPI/System/TwoWayAudio/channels/%s/audioData", c.host, session.ChannelID)
+	url := c.url(fmt.Sprintf("/ISAPI/System/TwoWayAudio/channels/%s/audioData", session.ChannelID))
 	// if session.SessionID != "" {
 	// url += "?sessionId=" + session.SessionID
 	// }
 
 	return &AudioStreamWriter{
-		client:   c,
-		session:  session,
-		url:      url,
-		stopChan: make(chan struct{}),
-		dataChan: make(chan []byte, 100),
-		errChan:  make(chan error, 1),
+		client:    c,
+		session:   session,
+		url:       url,
+		stopChan:  make(chan struct{}),
+		dataChan:  make(chan []byte, 100),
+		flushChan: make(chan chan struct{}),
+		loopDone:  make(chan struct{}),
+		frameSize: audio.SampleSize,
 	}
 }
 
-// Start begins the continuous sending loop
-func (w *AudioStreamWriter) Start() {
+// Start begins the continuous sending loop. The stream ends when ctx is cancelled or Close is called.
+func (w *AudioStreamWriter) Start(ctx context.Context) {
 	log.Printf("[Hikvision] AudioStreamWriter: Starting stream for channel %s", w.session.ChannelID)
+	ctx, w.cancel = context.WithCancel(ctx)
 	w.wg.Add(1)
-	go w.sendLoop()
+	go w.sendLoop(ctx)
 }
 
-// sendLoop continuously sends audio data via a persistent connection
-func (w *AudioStreamWriter) sendLoop() {
+// sendLoop continuously sends audio data via a persistent connection, reconnecting if it drops
+func (w *AudioStreamWriter) sendLoop(ctx context.Context) {
 	defer w.wg.Done()
+	defer close(w.loopDone)
+	defer func() {
+		for _, done := range w.flushes {
+			close(done)
+		}
+	}()
 
-	// Create a custom transport that gives us access to the connection
-	var conn net.Conn
+	reconnector := newReconnector(w.reconnect, "writer", w.session.ChannelID)
+	connected := false
+
+	for {
+		err := w.stream(ctx, func() {
+			connected = true
+			reconnector.connected()
+		})
 
-	transport := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			c, err := net.Dial(network, addr)
-			if err != nil {
-				return nil, err
+		select {
+		case <-w.stopChan:
+			return
+		default:
+		}
+		if ctx.Err() != nil {
+			w.err = ctx.Err()
+			return
+		}
+
+		// Failing to connect in the first place is reported as is
+		if !connected {
+			w.err = err
+			return
+		}
+
+		if _, ok := reconnector.retry(ctx, w.stopChan, err); !ok {
+			select {
+			case <-w.stopChan:
+			default:
+				w.err = err
 			}
-			conn = c
-			return c, nil
-		},
+			return
+		}
 	}
+}
+
+// stream sends audio over a single connection until it drops, calling onConnect once established
+func (w *AudioStreamWriter) stream(ctx context.Context, onConnect func()) error {
+	// Create a custom transport that gives us access to the connection
+	var conn net.Conn
+
+	transport := w.client.newStreamTransport(func(c net.Conn) {
+		conn = c
+	})
 
 	// Create HTTP client with our transport wrapped in digest auth
 	client := &http.Client{
-		Transport: &digest.Transport{
-			Username:  w.client.username,
-			Password:  w.client.password,
-			Transport: transport,
-		},
+		Transport: w.client.newAuthTransport(transport),
 	}
 
-	// Make the PUT request to establish the connection
-	req, err := http.NewRequest("PUT", w.url, nil)
+	// The connection lives as long as the request context
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Make the PUT request to establish the connection, recording how it was set up
+	setupCtx, setup := withStreamSetup(connCtx)
+	start := time.Now()
+	req, err := http.NewRequestWithContext(setupCtx, "PUT", w.url, nil)
 	if err != nil {
 		log.Printf("[Hikvision] AudioStreamWriter: Failed to create request: %v", err)
-		w.errChan <- err
-		return
+		return err
 	}
 
 	req.Header.Set("Content-Type", "application/octet-stream")
@@ -102,7 +179,7 @@ func (w *AudioStreamWriter) sendLoop() {
 			body, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 			log.Printf("[Hikvision] AudioStreamWriter: Error status %d, body: %s", resp.StatusCode, string(body))
-			errChan <- fmt.Errorf("status %d", resp.StatusCode)
+			errChan <- newError("AudioStreamWriter", resp.StatusCode, body)
 			return
 		}
 
@@ -117,25 +194,19 @@ func (w *AudioStreamWriter) sendLoop() {
 	case httpResp = <-respChan:
 		// Success
 	case err := <-errChan:
-		w.errChan <- err
-		return
-	case <-time.After(5 * time.Second):
+		return err
+	case <-time.After(w.client.streamConnectTimeout):
 		log.Printf("[Hikvision] AudioStreamWriter: Timeout waiting for response")
-		w.errChan <- fmt.Errorf("timeout")
-		return
-	}
-
-	if conn == nil {
-		log.Printf("[Hikvision] AudioStreamWriter: Connection not established")
-		w.errChan <- fmt.Errorf("connection not established")
-		return
+		return fmt.Errorf("timeout")
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.stopChan:
+		return nil
 	}
 
-	log.Printf("[Hikvision] AudioStreamWriter: Connection established, ready to send audio")
-
 	// Defer cleanup
 	defer func() {
-		if httpResp != nil && httpResp.Body != nil {
+		if httpResp.Body != nil {
 			httpResp.Body.Close()
 		}
 		if conn != nil {
@@ -143,37 +214,233 @@ func (w *AudioStreamWriter) sendLoop() {
 		}
 	}()
 
-	// Now write audio data directly to the connection
-	chunkCount := 0
+	if conn == nil {
+		log.Printf("[Hikvision] AudioStreamWriter: Connection not established")
+		return fmt.Errorf("connection not established")
+	}
+
+	saved := w.client.streamStats.record(setup, w.client.nonces.savedLatency())
+	log.Printf("[Hikvision] AudioStreamWriter: Connection established in %s (warm connection: %t, cached nonce: %t, saved %s), ready to send audio",
+		time.Since(start).Round(time.Millisecond), setup.warmConn, !setup.challenged, saved.Round(time.Millisecond))
+	onConnect()
+
+	return w.pace(ctx, conn)
+}
+
+// pace writes the audio to the connection in 20 ms frames, each at its slot on
+// an absolute playout timeline measured with the monotonic clock, so write time
+// and scheduler jitter do not accumulate into drift. It returns nil once the writer is closed.
+func (w *AudioStreamWriter) pace(ctx context.Context, conn net.Conn) error {
+	// Frames are written playoutLead ahead of their slot to absorb network jitter
+	next := time.Now().Add(-playoutLead)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
 	for {
+		// Stop taking input once a frame is ready, so fast writers block in Write.
+		// Live sources are always read, and trimmed instead.
+		var input <-chan []byte
+		if len(w.pending) < w.frameSize || w.maxLatency > 0 {
+			input = w.dataChan
+		}
+
 		select {
 		case <-w.stopChan:
-			log.Printf("[Hikvision] AudioStreamWriter: Stopped after %d chunks", chunkCount)
-			return
+			stats := w.Stats()
+			log.Printf("[Hikvision] AudioStreamWriter: Stopped after %d frames (%d underruns, %d silence frames, %d resyncs, %d bytes dropped, max buffered %s)",
+				stats.Frames, stats.Underruns, stats.SilenceFrames, stats.Resyncs, stats.DroppedBytes, stats.MaxBufferedLatency)
+			return nil
+
+		case <-ctx.Done():
+			log.Printf("[Hikvision] AudioStreamWriter: Cancelled after %d frames", w.Stats().Frames)
+			return ctx.Err()
+
+		case done := <-w.flushChan:
+			w.flushes = append(w.flushes, done)
+			continue
+
+		case data := <-input:
+			w.pending = w.take(w.pending, data)
+			continue
 
-		case data := <-w.dataChan:
-			if len(data) == 0 {
-				continue
+		case <-timer.C:
+		}
+
+		// Top up a partial frame with audio that arrived along with the tick
+	topUp:
+		for len(w.pending) < w.frameSize {
+			select {
+			case data := <-w.dataChan:
+				w.pending = w.take(w.pending, data)
+			default:
+				break topUp
 			}
+		}
+
+		// Recover from falling behind (e.g. a stalled write) by restarting the
+		// timeline instead of bursting the missed frames
+		if late := time.Since(next); late > maxFrameLateness {
+			w.updateStats(func(s *AudioStreamWriterStats) { s.Resyncs++ })
+			log.Printf("[Hikvision] AudioStreamWriter: %s behind schedule, resyncing", late.Round(time.Millisecond))
+			next = time.Now()
+		}
+
+		frame := w.pending
+		if len(frame) > w.frameSize {
+			frame = frame[:w.frameSize]
+		}
+		// A partial frame waits for more audio, unless it is the end of a flush
+		if len(frame) < w.frameSize && (len(w.flushes) == 0 || w.queued.Load() > 0) {
+			frame = nil
+		}
 
-			chunkCount++
-			_, err := conn.Write(data)
-			if err != nil {
+		switch {
+		case len(frame) > 0:
+			if _, err := conn.Write(frame); err != nil {
 				log.Printf("[Hikvision] AudioStreamWriter: Failed to write data: %v", err)
-				w.errChan <- err
-				return
+				return err
+			}
+			w.pending = w.pending[len(frame):]
+			w.updateStats(func(s *AudioStreamWriterStats) { s.Frames++ })
+		case w.silence != nil:
+			// Keep the stream busy, some firmware drops it after a few idle seconds
+			if _, err := conn.Write(w.silence); err != nil {
+				log.Printf("[Hikvision] AudioStreamWriter: Failed to write silence: %v", err)
+				return err
 			}
+			w.updateStats(func(s *AudioStreamWriterStats) {
+				s.Underruns++
+				s.SilenceFrames++
+			})
+		default:
+			w.updateStats(func(s *AudioStreamWriterStats) { s.Underruns++ })
+		}
 
-			// Add delay to match audio playback rate
-			// G.711 is 8000 samples/sec = 8000 bytes/sec
-			// For each chunk, delay = (chunk_size / 8000) seconds
-			chunkDuration := time.Duration(len(data)) * time.Second / 8000
-			time.Sleep(chunkDuration)
+		buffered := w.bufferedLatency(len(w.pending))
+		w.updateStats(func(s *AudioStreamWriterStats) {
+			s.BufferedLatency = buffered
+			if buffered > s.MaxBufferedLatency {
+				s.MaxBufferedLatency = buffered
+			}
+		})
 
-			if chunkCount%100 == 0 {
-				log.Printf("[Hikvision] AudioStreamWriter: Sent %d chunks so far", chunkCount)
+		if len(w.pending) == 0 && w.queued.Load() == 0 {
+			for _, done := range w.flushes {
+				close(done)
 			}
+			w.flushes = nil
 		}
+
+		next = next.Add(audio.SampleDuration)
+		timer.Reset(time.Until(next))
+	}
+}
+
+// take appends audio from dataChan to the pending audio
+func (w *AudioStreamWriter) take(pending, data []byte) []byte {
+	w.queued.Add(-int64(len(data)))
+	return w.trim(append(pending, data...))
+}
+
+// trim drops the oldest audio when more than maxLatency is buffered, so bursts
+// from a live source do not turn into a lasting delay
+func (w *AudioStreamWriter) trim(pending []byte) []byte {
+	if w.maxLatency <= 0 {
+		return pending
+	}
+
+	excess := len(pending) + int(w.queued.Load()) - w.durationToBytes(w.maxLatency)
+	if excess <= 0 {
+		return pending
+	}
+	// Drop whole frames to keep the frame boundaries
+	excess = (excess + w.frameSize - 1) / w.frameSize * w.frameSize
+	if excess > len(pending) {
+		excess = len(pending) / w.frameSize * w.frameSize
+	}
+
+	w.updateStats(func(s *AudioStreamWriterStats) { s.DroppedBytes += excess })
+	return pending[excess:]
+}
+
+// bufferedLatency returns how long the audio waiting to be sent plays for
+func (w *AudioStreamWriter) bufferedLatency(pending int) time.Duration {
+	return w.bytesToDuration(pending + int(w.queued.Load()))
+}
+
+// bytesToDuration converts an amount of audio in the codec to its duration
+func (w *AudioStreamWriter) bytesToDuration(n int) time.Duration {
+	return time.Duration(n) * audio.SampleDuration / time.Duration(w.frameSize)
+}
+
+// durationToBytes converts a duration to the amount of audio it takes in the codec
+func (w *AudioStreamWriter) durationToBytes(d time.Duration) int {
+	return int(d * time.Duration(w.frameSize) / audio.SampleDuration)
+}
+
+func (w *AudioStreamWriter) updateStats(update func(*AudioStreamWriterStats)) {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	update(&w.stats)
+}
+
+// Stats returns the pacing statistics of the writer
+func (w *AudioStreamWriter) Stats() AudioStreamWriterStats {
+	w.statsMu.Lock()
+	defer w.statsMu.Unlock()
+	return w.stats
+}
+
+// SetMaxLatency bounds the audio buffered in the writer for live sources; older
+// audio is dropped beyond it. Zero (the default) never drops audio and makes
+// Write block instead, which suits files. Call it before Start.
+func (w *AudioStreamWriter) SetMaxLatency(d time.Duration) {
+	w.maxLatency = d
+}
+
+// SetCodec sets the codec of the audio, which decides how many bytes make a
+// frame. The default is G.711; other 64 kbit/s codecs frame the same way.
+// Call it before Start.
+func (w *AudioStreamWriter) SetCodec(codec audio.Codec) {
+	w.frameSize = codec.FrameSize()
+}
+
+// SetSilence makes the writer send silence in the given codec whenever it has
+// no audio, so the device does not close a stream whose source went quiet.
+// Codecs without a silence frame (G.722, G.726) are not filled. Call it before Start.
+func (w *AudioStreamWriter) SetSilence(codec audio.Codec) {
+	w.silence, _ = audio.SilenceFrame(codec)
+}
+
+// SetReconnect configures how the stream recovers from a dropped connection. Call it before Start.
+func (w *AudioStreamWriter) SetReconnect(policy ReconnectPolicy) {
+	w.reconnect = policy
+}
+
+// Flush waits until all audio written so far has been sent to the device
+func (w *AudioStreamWriter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case w.flushChan <- done:
+	case <-w.loopDone:
+		return w.loopErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-done:
+		// sendLoop also releases flushes when it fails
+		select {
+		case <-w.loopDone:
+			if w.err != nil {
+				return w.err
+			}
+		default:
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -182,20 +449,34 @@ func (w *AudioStreamWriter) Write(p []byte) (n int, err error) {
 	data := make([]byte, len(p))
 	copy(data, p)
 
+	w.queued.Add(int64(len(data)))
 	select {
 	case w.dataChan <- data:
 		return len(p), nil
 	case <-w.stopChan:
+		w.queued.Add(-int64(len(data)))
 		return 0, io.ErrClosedPipe
-	case err := <-w.errChan:
-		return 0, err
+	case <-w.loopDone:
+		w.queued.Add(-int64(len(data)))
+		return 0, w.loopErr()
+	}
+}
+
+// loopErr returns the error that ended sendLoop, once loopDone is closed
+func (w *AudioStreamWriter) loopErr() error {
+	if w.err != nil {
+		return w.err
 	}
+	return io.ErrClosedPipe
 }
 
 // Close stops the audio stream writer and waits for cleanup to complete
 func (w *AudioStreamWriter) Close() error {
 	w.closeOnce.Do(func() {
 		close(w.stopChan)
+		if w.cancel != nil {
+			w.cancel()
+		}
 		w.wg.Wait() // Wait for sendLoop to complete cleanup
 		log.Printf("[Hikvision] AudioStreamWriter: Cleanup complete for channel %s", w.session.ChannelID)
 	})
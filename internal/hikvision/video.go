@@ -0,0 +1,53 @@
+package hikvision
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// VideoStream selects one of the device's RTSP video streams
+type VideoStream string
+
+const (
+	// VideoStreamMain is the full resolution stream
+	VideoStreamMain VideoStream = "main"
+	// VideoStreamSub is the low resolution stream, lighter on bandwidth and CPU
+	VideoStreamSub VideoStream = "sub"
+)
+
+// defaultRTSPPort is the RTSP port of Hikvision devices
+const defaultRTSPPort = 554
+
+// rtspChannels maps a video stream to its ISAPI streaming channel (camera 1)
+var rtspChannels = map[VideoStream]string{
+	VideoStreamMain: "101",
+	VideoStreamSub:  "102",
+}
+
+// RTSPSource returns the RTSP URL of a video stream and the credentials to use with it.
+// A zero port uses the default RTSP port.
+func (c *Client) RTSPSource(stream VideoStream, port int) (string, string, string, error) {
+	channel, ok := rtspChannels[stream]
+	if !ok {
+		return "", "", "", fmt.Errorf("unknown video stream %q (use %q or %q)", stream, VideoStreamMain, VideoStreamSub)
+	}
+	if port == 0 {
+		port = defaultRTSPPort
+	}
+
+	// The ISAPI host may carry the HTTP port
+	hostname := c.host
+	if h, _, err := net.SplitHostPort(c.host); err == nil {
+		hostname = h
+	}
+
+	u := url.URL{
+		Scheme: "rtsp",
+		Host:   net.JoinHostPort(hostname, strconv.Itoa(port)),
+		Path:   "/Streaming/Channels/" + channel,
+	}
+	username, password := c.credentials()
+	return u.String(), username, password, nil
+}
@@ -0,0 +1,364 @@
+// Package rtsp is a minimal RTSP client that receives RTP over the RTSP TCP
+// connection (interleaved mode), which is all we need to pull a camera stream.
+package rtsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icholy/digest"
+	"github.com/pion/sdp/v3"
+)
+
+// DefaultPort is the standard RTSP port
+const DefaultPort = 554
+
+// ErrUnauthorized is returned when the server rejects the credentials
+var ErrUnauthorized = errors.New("rtsp: unauthorized")
+
+// Response is an RTSP response
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     textproto.MIMEHeader
+	Body       []byte
+}
+
+// Client is a single RTSP session over TCP
+type Client struct {
+	url      *url.URL // Stream URL without credentials
+	username string
+	password string
+	timeout  time.Duration // Deadline for each request and packet read
+
+	conn net.Conn
+	br   *bufio.Reader
+
+	mu      sync.Mutex // Serializes writes (requests and keepalives)
+	cseq    int
+	session string
+	authFn  func(method, uri string) (string, error) // Builds the Authorization header once challenged
+}
+
+// Dial connects to the RTSP server of rawURL
+func Dial(ctx context.Context, rawURL, username, password string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "rtsp" {
+		return nil, fmt.Errorf("rtsp: unsupported scheme %q", u.Scheme)
+	}
+	u.User = nil
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), strconv.Itoa(DefaultPort))
+	}
+
+	var dialer net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dialer.DialContext(dialCtx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		url:      u,
+		username: username,
+		password: password,
+		timeout:  timeout,
+		conn:     conn,
+		br:       bufio.NewReaderSize(conn, 64*1024),
+	}, nil
+}
+
+// Describe returns the session description of the stream and the base URL
+// that relative track controls are resolved against
+func (c *Client) Describe() (*sdp.SessionDescription, string, error) {
+	resp, err := c.do("DESCRIBE", c.url.String(), map[string]string{"Accept": "application/sdp"})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var desc sdp.SessionDescription
+	if err := desc.Unmarshal(resp.Body); err != nil {
+		return nil, "", fmt.Errorf("rtsp: invalid session description: %w", err)
+	}
+
+	base := resp.Header.Get("Content-Base")
+	if base == "" {
+		base = c.url.String()
+	}
+	return &desc, base, nil
+}
+
+// Setup sets up a track to be delivered on the given interleaved RTP channel
+// (RTCP uses the next channel)
+func (c *Client) Setup(control string, channel int) error {
+	transport := fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channel, channel+1)
+	resp, err := c.do("SETUP", control, map[string]string{"Transport": transport})
+	if err != nil {
+		return err
+	}
+
+	// "Session: 12345678;timeout=60"
+	session, _, _ := strings.Cut(resp.Header.Get("Session"), ";")
+	c.session = strings.TrimSpace(session)
+	return nil
+}
+
+// Play starts the delivery of the set-up tracks
+func (c *Client) Play() error {
+	_, err := c.do("PLAY", c.url.String(), map[string]string{"Range": "npt=0.000-"})
+	return err
+}
+
+// KeepAlive keeps the session alive. It does not wait for the response, which
+// ReadPacket skips, so it can be called while packets are being read.
+func (c *Client) KeepAlive() error {
+	return c.write("OPTIONS", c.url.String(), nil)
+}
+
+// ReadPacket returns the next interleaved packet and its channel
+func (c *Client) ReadPacket() (int, []byte, error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if b[0] != '$' {
+			// Response to a keepalive
+			if _, err := c.readResponse(); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+
+		var header [4]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return 0, nil, err
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[2:]))
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		return int(header[1]), payload, nil
+	}
+}
+
+// Close tears the session down and closes the connection
+func (c *Client) Close() error {
+	if c.session != "" {
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.write("TEARDOWN", c.url.String(), nil)
+	}
+	return c.conn.Close()
+}
+
+// FindTrack returns the control URL and payload type of the first media of the
+// given kind (e.g. "video") using the given codec (e.g. "H264")
+func FindTrack(desc *sdp.SessionDescription, base, media, codec string) (string, uint8, error) {
+	for _, md := range desc.MediaDescriptions {
+		if md.MediaName.Media != media {
+			continue
+		}
+		for _, format := range md.MediaName.Formats {
+			pt, err := strconv.ParseUint(format, 10, 8)
+			if err != nil {
+				continue
+			}
+			c, err := desc.GetCodecForPayloadType(uint8(pt))
+			if err != nil || !strings.EqualFold(c.Name, codec) {
+				continue
+			}
+			control, _ := md.Attribute("control")
+			return ResolveControl(base, control), uint8(pt), nil
+		}
+	}
+	return "", 0, fmt.Errorf("rtsp: no %s %s track", media, codec)
+}
+
+// ResolveControl returns the absolute URL of a track control attribute
+func ResolveControl(base, control string) string {
+	switch {
+	case control == "" || control == "*":
+		return base
+	case strings.HasPrefix(control, "rtsp://"):
+		return control
+	case strings.HasSuffix(base, "/"):
+		return base + control
+	default:
+		return base + "/" + control
+	}
+}
+
+// do sends a request and waits for its response, authenticating if challenged
+func (c *Client) do(method, uri string, header map[string]string) (*Response, error) {
+	resp, err := c.roundTrip(method, uri, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.authFn == nil {
+		if err := c.setupAuth(resp.Header); err != nil {
+			return nil, err
+		}
+		resp, err = c.roundTrip(method, uri, header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("rtsp: %s failed: %d %s", method, resp.StatusCode, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *Client) roundTrip(method, uri string, header map[string]string) (*Response, error) {
+	if err := c.write(method, uri, header); err != nil {
+		return nil, err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.readResponse()
+}
+
+// setupAuth prepares the Authorization header from a 401 challenge, preferring digest over basic
+func (c *Client) setupAuth(header textproto.MIMEHeader) error {
+	if chal, err := digest.FindChallenge(http.Header(header)); err == nil {
+		count := 0
+		c.authFn = func(method, uri string) (string, error) {
+			count++
+			cred, err := digest.Digest(chal, digest.Options{
+				Method:   method,
+				URI:      uri,
+				Count:    count,
+				Username: c.username,
+				Password: c.password,
+			})
+			if err != nil {
+				return "", err
+			}
+			return cred.String(), nil
+		}
+		return nil
+	}
+
+	for _, value := range header.Values("Www-Authenticate") {
+		if strings.HasPrefix(strings.ToLower(value), "basic") {
+			token := base64.StdEncoding.EncodeToString([]byte(c.username + ":" + c.password))
+			c.authFn = func(string, string) (string, error) {
+				return "Basic " + token, nil
+			}
+			return nil
+		}
+	}
+
+	return ErrUnauthorized
+}
+
+// write sends a request without reading the response
+func (c *Client) write(method, uri string, header map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cseq++
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s RTSP/1.0\r\n", method, uri)
+	fmt.Fprintf(&b, "CSeq: %d\r\n", c.cseq)
+	b.WriteString("User-Agent: hikvision-doorbell-server\r\n")
+	if c.session != "" {
+		fmt.Fprintf(&b, "Session: %s\r\n", c.session)
+	}
+	if c.authFn != nil {
+		auth, err := c.authFn(method, uri)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "Authorization: %s\r\n", auth)
+	}
+	for k, v := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+	b.WriteString("\r\n")
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+// readResponse reads a response, skipping interleaved packets that arrive before it
+func (c *Client) readResponse() (*Response, error) {
+	for {
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '$' {
+			break
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return nil, err
+		}
+		if _, err := c.br.Discard(int(binary.BigEndian.Uint16(header[2:]))); err != nil {
+			return nil, err
+		}
+	}
+
+	tp := textproto.NewReader(c.br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	// "RTSP/1.0 200 OK"
+	proto, status, ok := strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(proto, "RTSP/") {
+		return nil, fmt.Errorf("rtsp: malformed status line %q", line)
+	}
+	codeStr, reason, _ := strings.Cut(status, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return nil, fmt.Errorf("rtsp: malformed status line %q", line)
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Response{
+		StatusCode: code,
+		Status:     reason,
+		Header:     header,
+	}
+	if n, _ := strconv.Atoi(header.Get("Content-Length")); n > 0 {
+		resp.Body = make([]byte, n)
+		if _, err := io.ReadFull(c.br, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -2,8 +2,11 @@ package session
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strings"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
 	"github.com/acardace/hikvision-doorbell-server/internal/logger"
 )
@@ -11,19 +14,22 @@ import (
 // HikvisionSessionManager implements SessionManager for Hikvision devices
 type HikvisionSessionManager struct {
 	client *hikvision.Client
+	codec  string // Codec to switch channels to before opening (empty keeps the device setting)
 }
 
 // NewHikvisionSessionManager creates a new Hikvision session manager
-func NewHikvisionSessionManager(client *hikvision.Client) *HikvisionSessionManager {
+// If codec is set, channels are switched to it before being opened.
+func NewHikvisionSessionManager(client *hikvision.Client, codec string) *HikvisionSessionManager {
 	return &HikvisionSessionManager{
 		client: client,
+		codec:  codec,
 	}
 }
 
-// AcquireChannel finds and opens an available audio channel
-func (m *HikvisionSessionManager) AcquireChannel(ctx context.Context) (*AudioSession, error) {
+// findAvailableChannel returns the first channel that is not in use
+func (m *HikvisionSessionManager) findAvailableChannel(ctx context.Context) (*hikvision.TwoWayAudioChannel, error) {
 	// Get available channels from device
-	channels, err := m.client.GetTwoWayAudioChannels()
+	channels, err := m.client.GetTwoWayAudioChannels(ctx)
 	if err != nil {
 		logger.Log.Error("failed to get audio channels",
 			slog.String("component", "session_manager"),
@@ -38,23 +44,75 @@ func (m *HikvisionSessionManager) AcquireChannel(ctx context.Context) (*AudioSes
 	}
 
 	// Find first available channel (Enabled == "false" means available)
-	var channelID string
-	for _, ch := range channels.Channels {
-		if ch.Enabled == "false" {
-			channelID = ch.ID
-			break
+	for i := range channels.Channels {
+		if channels.Channels[i].Enabled == "false" {
+			return &channels.Channels[i], nil
 		}
 	}
 
-	if channelID == "" {
-		logger.Log.Warn("no available channels, all in use",
+	logger.Log.Warn("no available channels, all in use",
+		slog.String("component", "session_manager"),
+		slog.Int("total_channels", len(channels.Channels)))
+	return nil, ErrNoAvailableChannels
+}
+
+// targetCodec returns the codec a channel should use, given its current setting.
+// Without a configured codec the device setting is kept, so a channel using a
+// codec we cannot stream (e.g. AAC) is reported with ErrUnsupportedCodec.
+func (m *HikvisionSessionManager) targetCodec(current string) (string, error) {
+	if m.codec != "" {
+		return m.codec, nil
+	}
+	if _, ok := audio.CodecByName(current); !ok {
+		logger.Log.Warn("audio channel uses an unsupported codec, set hikvision.codec to switch it",
 			slog.String("component", "session_manager"),
-			slog.Int("total_channels", len(channels.Channels)))
-		return nil, ErrNoAvailableChannels
+			slog.String("codec", current))
+		return "", fmt.Errorf("%w %q (set codec to G.711ulaw, G.711alaw, G.722 or G.726)", ErrUnsupportedCodec, current)
+	}
+	return current, nil
+}
+
+// ChannelCodec returns the codec the next acquired channel will use
+func (m *HikvisionSessionManager) ChannelCodec(ctx context.Context) (string, error) {
+	ch, err := m.findAvailableChannel(ctx)
+	if err != nil {
+		return "", err
+	}
+	return m.targetCodec(ch.AudioCompressionType)
+}
+
+// AcquireChannel finds and opens an available audio channel
+func (m *HikvisionSessionManager) AcquireChannel(ctx context.Context) (*AudioSession, error) {
+	ch, err := m.findAvailableChannel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channelID := ch.ID
+
+	codec, err := m.targetCodec(ch.AudioCompressionType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Switch the channel codec only when one is configured and differs
+	if m.codec != "" && !strings.EqualFold(codec, ch.AudioCompressionType) {
+		logger.Log.Info("switching audio channel codec",
+			slog.String("component", "session_manager"),
+			slog.String("channel_id", channelID),
+			slog.String("from", ch.AudioCompressionType),
+			slog.String("to", codec))
+
+		if err := m.client.SetAudioCompressionType(ctx, channelID, codec); err != nil {
+			logger.Log.Error("failed to switch audio channel codec",
+				slog.String("component", "session_manager"),
+				slog.String("channel_id", channelID),
+				slog.String("error", err.Error()))
+			return nil, err
+		}
 	}
 
 	// Open the channel
-	hikSession, err := m.client.OpenAudioChannel(channelID)
+	hikSession, err := m.client.OpenAudioChannel(ctx, channelID)
 	if err != nil {
 		logger.Log.Error("failed to open audio channel",
 			slog.String("component", "session_manager"),
@@ -66,17 +124,19 @@ func (m *HikvisionSessionManager) AcquireChannel(ctx context.Context) (*AudioSes
 	logger.Log.Info("acquired audio channel",
 		slog.String("component", "session_manager"),
 		slog.String("channel_id", channelID),
-		slog.String("session_id", hikSession.SessionID))
+		slog.String("session_id", hikSession.SessionID),
+		slog.String("codec", codec))
 
 	return &AudioSession{
 		ChannelID: hikSession.ChannelID,
 		SessionID: hikSession.SessionID,
+		Codec:     codec,
 	}, nil
 }
 
 // ReleaseChannel closes an audio channel by its ID
 func (m *HikvisionSessionManager) ReleaseChannel(ctx context.Context, channelID string) error {
-	err := m.client.CloseAudioChannel(channelID)
+	err := m.client.CloseAudioChannel(ctx, channelID)
 	if err != nil {
 		logger.Log.Error("failed to close audio channel",
 			slog.String("component", "session_manager"),
@@ -92,9 +152,40 @@ func (m *HikvisionSessionManager) ReleaseChannel(ctx context.Context, channelID
 	return nil
 }
 
+// ReopenChannel closes and opens the channel of a session again, keeping its codec
+func (m *HikvisionSessionManager) ReopenChannel(ctx context.Context, sess *AudioSession) (*AudioSession, error) {
+	// The device may already consider the channel closed
+	if err := m.client.CloseAudioChannel(ctx, sess.ChannelID); err != nil {
+		logger.Log.Debug("closing audio channel before re-opening failed",
+			slog.String("component", "session_manager"),
+			slog.String("channel_id", sess.ChannelID),
+			slog.String("error", err.Error()))
+	}
+
+	hikSession, err := m.client.OpenAudioChannel(ctx, sess.ChannelID)
+	if err != nil {
+		logger.Log.Error("failed to re-open audio channel",
+			slog.String("component", "session_manager"),
+			slog.String("channel_id", sess.ChannelID),
+			slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	logger.Log.Info("re-opened audio channel",
+		slog.String("component", "session_manager"),
+		slog.String("channel_id", sess.ChannelID),
+		slog.String("session_id", hikSession.SessionID))
+
+	return &AudioSession{
+		ChannelID: hikSession.ChannelID,
+		SessionID: hikSession.SessionID,
+		Codec:     sess.Codec,
+	}, nil
+}
+
 // ListChannels returns all available channels and their status
 func (m *HikvisionSessionManager) ListChannels(ctx context.Context) ([]ChannelInfo, error) {
-	channels, err := m.client.GetTwoWayAudioChannels()
+	channels, err := m.client.GetTwoWayAudioChannels(ctx)
 	if err != nil {
 		logger.Log.Error("failed to get audio channels",
 			slog.String("component", "session_manager"),
@@ -107,6 +198,7 @@ func (m *HikvisionSessionManager) ListChannels(ctx context.Context) ([]ChannelIn
 		result = append(result, ChannelInfo{
 			ID:      ch.ID,
 			Enabled: ch.Enabled == "true",
+			Codec:   ch.AudioCompressionType,
 		})
 	}
 
@@ -8,18 +8,24 @@ import (
 var (
 	// ErrNoAvailableChannels is returned when all channels are in use
 	ErrNoAvailableChannels = errors.New("no available channels")
+
+	// ErrUnsupportedCodec is returned when a channel uses a codec that cannot be
+	// streamed (e.g. AAC) and no codec is configured to switch it to
+	ErrUnsupportedCodec = errors.New("unsupported device codec")
 )
 
 // AudioSession represents an active audio session with a device
 type AudioSession struct {
 	ChannelID string
 	SessionID string
+	Codec     string // Device codec name (e.g. "G.711ulaw")
 }
 
 // ChannelInfo represents information about an audio channel
 type ChannelInfo struct {
 	ID      string
-	Enabled bool // true if channel is currently in use
+	Enabled bool   // true if channel is currently in use
+	Codec   string // Device codec name (e.g. "G.711ulaw")
 }
 
 // SessionManager manages audio sessions with devices
@@ -31,6 +37,13 @@ type SessionManager interface {
 	// ReleaseChannel closes an audio channel by its ID
 	ReleaseChannel(ctx context.Context, channelID string) error
 
+	// ReopenChannel closes and opens the channel of a session again, for when
+	// the device dropped it, and returns the new session
+	ReopenChannel(ctx context.Context, sess *AudioSession) (*AudioSession, error)
+
 	// ListChannels returns all available channels and their status
 	ListChannels(ctx context.Context) ([]ChannelInfo, error)
+
+	// ChannelCodec returns the codec the next acquired channel will use
+	ChannelCodec(ctx context.Context) (string, error)
 }
@@ -0,0 +1,185 @@
+package streaming
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/audio"
+)
+
+// Echo suppression defaults
+const (
+	DefaultEchoThreshold   = -40.0 // dBFS
+	DefaultEchoAttenuation = 30.0  // dB
+	DefaultEchoHangTime    = 300 * time.Millisecond
+
+	// echoReleaseMargin puts the default release threshold below the threshold,
+	// so speech hovering around the threshold does not toggle suppression
+	echoReleaseMargin = 6.0
+
+	// minReportedLevel is reported for client audio quieter than this, digital silence included
+	minReportedLevel = -100.0
+)
+
+// EchoSuppression configures half-duplex echo suppression: while the client
+// speaks, the device microphone audio sent back to it is attenuated, so the
+// client does not hear itself through the doorbell speaker and microphone.
+type EchoSuppression struct {
+	Enabled bool
+
+	// Threshold is the level in dBFS above which client audio is taken as
+	// speech, zero for DefaultEchoThreshold
+	Threshold float64
+
+	// ReleaseThreshold is the level in dBFS the client audio must fall below
+	// for the speech to end, zero for 6 dB under Threshold
+	ReleaseThreshold float64
+
+	// Attenuation is applied to device audio while the client speaks, in dB,
+	// zero for DefaultEchoAttenuation
+	Attenuation float64
+
+	// HangTime keeps the device audio attenuated after the client speech ends,
+	// until the echo has died down, zero for DefaultEchoHangTime
+	HangTime time.Duration
+}
+
+// withDefaults returns the settings with zero values replaced by the defaults
+func (e EchoSuppression) withDefaults() EchoSuppression {
+	if e.Threshold == 0 {
+		e.Threshold = DefaultEchoThreshold
+	}
+	if e.ReleaseThreshold == 0 {
+		e.ReleaseThreshold = e.Threshold - echoReleaseMargin
+	}
+	if e.Attenuation == 0 {
+		e.Attenuation = DefaultEchoAttenuation
+	}
+	if e.HangTime == 0 {
+		e.HangTime = DefaultEchoHangTime
+	}
+	return e
+}
+
+// Validate checks that the settings are in range
+func (e EchoSuppression) Validate() error {
+	e = e.withDefaults()
+	if !(e.Threshold >= -80 && e.Threshold <= 0) {
+		return fmt.Errorf("threshold must be between -80 and 0 dBFS")
+	}
+	if !(e.ReleaseThreshold > minReportedLevel && e.ReleaseThreshold <= e.Threshold) {
+		return fmt.Errorf("release threshold must be between %g dBFS and the threshold", minReportedLevel)
+	}
+	if !(e.Attenuation > 0 && e.Attenuation <= 60) {
+		return fmt.Errorf("attenuation must be between 0 and 60 dB")
+	}
+	if e.HangTime < 0 {
+		return fmt.Errorf("hang time must not be negative")
+	}
+	return nil
+}
+
+// EchoSuppressionStats reports the state of the echo suppressor of a session
+type EchoSuppressionStats struct {
+	Enabled          bool
+	Active           bool    // Device audio is being attenuated
+	ClientLevel      float64 // Level of the last client audio in dBFS
+	Activations      int     // Times the client started speaking
+	SuppressedFrames int     // Device audio frames attenuated
+}
+
+// EchoSuppressor attenuates device audio while the client speaks. Client and
+// device audio are fed from their own goroutines.
+type EchoSuppressor struct {
+	cfg         EchoSuppression
+	attenuation float64 // Linear gain applied while active
+
+	mu          sync.Mutex
+	active      bool
+	lastSpeech  time.Time
+	gain        float64 // Gain applied to the end of the last device frame
+	clientLevel float64
+	activations int
+	suppressed  int
+}
+
+// NewEchoSuppressor creates an echo suppressor
+func NewEchoSuppressor(cfg EchoSuppression) *EchoSuppressor {
+	cfg = cfg.withDefaults()
+	return &EchoSuppressor{
+		cfg:         cfg,
+		attenuation: math.Pow(10, -cfg.Attenuation/20),
+		gain:        1,
+		clientLevel: minReportedLevel,
+	}
+}
+
+// Client watches a frame of client audio for speech
+func (e *EchoSuppressor) Client(samples []int16) {
+	level := max(audio.Level(samples), minReportedLevel)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.clientLevel = level
+	threshold := e.cfg.Threshold
+	if e.active {
+		threshold = e.cfg.ReleaseThreshold
+	}
+	if level > threshold {
+		if !e.active {
+			e.active = true
+			e.activations++
+		}
+		e.lastSpeech = time.Now()
+	}
+}
+
+// Device attenuates a frame of device audio in place if the client is
+// speaking and reports whether it changed the audio
+func (e *EchoSuppressor) Device(samples []int16) bool {
+	e.mu.Lock()
+	e.expire()
+	from, to := e.gain, 1.0
+	if e.active {
+		to = e.attenuation
+		e.suppressed++
+	}
+	e.gain = to
+	e.mu.Unlock()
+
+	if from == 1 && to == 1 {
+		return false
+	}
+
+	// Ramp from the last gain over the frame to avoid clicks
+	for i, s := range samples {
+		g := from + (to-from)*float64(i+1)/float64(len(samples))
+		samples[i] = int16(math.Round(float64(s) * g))
+	}
+	return true
+}
+
+// Stats returns the state of the suppressor
+func (e *EchoSuppressor) Stats() EchoSuppressionStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expire()
+	return EchoSuppressionStats{
+		Enabled:          true,
+		Active:           e.active,
+		ClientLevel:      e.clientLevel,
+		Activations:      e.activations,
+		SuppressedFrames: e.suppressed,
+	}
+}
+
+// expire ends the suppression once the hang time has passed since the last
+// speech, also when the client stopped sending audio (e.g. muted)
+func (e *EchoSuppressor) expire() {
+	if e.active && time.Since(e.lastSpeech) > e.cfg.HangTime {
+		e.active = false
+	}
+}
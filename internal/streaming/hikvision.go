@@ -2,8 +2,11 @@ package streaming
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/acardace/hikvision-doorbell-server/internal/audio"
 	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
@@ -13,49 +16,184 @@ import (
 	"github.com/pion/webrtc/v4/pkg/media"
 )
 
+// clientToDeviceMaxLatency bounds the client audio buffered for the device,
+// so bursts after network hiccups do not delay the conversation
+const clientToDeviceMaxLatency = 200 * time.Millisecond
+
+// reopenDebounce lets the reader and writer share one channel re-open when they drop together
+const reopenDebounce = 5 * time.Second
+
 // HikvisionAudioStreamer implements AudioStreamer for Hikvision devices
 type HikvisionAudioStreamer struct {
-	client      *hikvision.Client
-	audioWriter *hikvision.AudioStreamWriter
-	audioReader *hikvision.AudioStreamReader
+	client         *hikvision.Client
+	sessionManager session.SessionManager
+	audioWriter    *hikvision.AudioStreamWriter // Set and cleared under reopenMu, for Stats
+	audioReader    *hikvision.AudioStreamReader
+	clientCodec    audio.Codec // Codec sent to the WebRTC client
+	deviceCodec    audio.Codec // Codec used by the device channel
+	toClient       *audio.Transcoder
+	processing     audio.Processing // Processing of client audio sent to the device
+	speaker        io.Writer        // audioWriter, behind the processing if any
+	echoConfig     EchoSuppression
+	echo           *EchoSuppressor // nil when echo suppression is disabled
+	onReconnect    func(ReconnectEvent)
+
+	reopenMu   sync.Mutex
+	session    *session.AudioSession // Current session, replaced when the channel is re-opened
+	reopenedAt time.Time
 }
 
 // NewHikvisionAudioStreamer creates a new Hikvision audio streamer
-func NewHikvisionAudioStreamer(client *hikvision.Client) *HikvisionAudioStreamer {
+// clientCodec is the codec sent to the WebRTC client; audio is transcoded
+// only if the device channel uses a different one. The client audio is
+// transcoded from whichever negotiated codec its track uses.
+// The client audio is processed with processing on its way to the device.
+// The device streams reconnect on their own if they drop, re-opening the
+// channel through sessionManager if needed; onReconnect (optional) is told about it.
+func NewHikvisionAudioStreamer(client *hikvision.Client, sessionManager session.SessionManager, clientCodec audio.Codec, processing audio.Processing, onReconnect func(ReconnectEvent)) *HikvisionAudioStreamer {
 	return &HikvisionAudioStreamer{
-		client: client,
+		client:         client,
+		sessionManager: sessionManager,
+		clientCodec:    clientCodec,
+		processing:     processing,
+		onReconnect:    onReconnect,
 	}
 }
 
+// SetEchoSuppression configures echo suppression for the session. It needs a
+// G.711 device codec and is left off with others. Call it before Start.
+func (s *HikvisionAudioStreamer) SetEchoSuppression(cfg EchoSuppression) {
+	s.echoConfig = cfg
+}
+
 // Start begins the audio streaming session
 func (s *HikvisionAudioStreamer) Start(ctx context.Context, sess *session.AudioSession) error {
+	deviceCodec := audio.PCMU
+	if sess.Codec != "" {
+		c, ok := audio.CodecByName(sess.Codec)
+		if !ok {
+			return fmt.Errorf("unsupported device codec %q", sess.Codec)
+		}
+		deviceCodec = c
+	}
+	toClient, err := audio.NewTranscoder(deviceCodec, s.clientCodec)
+	if err != nil {
+		return fmt.Errorf("device codec %s: %w", deviceCodec.Name, err)
+	}
+	s.deviceCodec = deviceCodec
+	s.toClient = toClient
+
+	s.session = sess
+
+	if s.echoConfig.Enabled {
+		if s.deviceCodec == audio.PCMU || s.deviceCodec == audio.PCMA {
+			s.echo = NewEchoSuppressor(s.echoConfig)
+		} else {
+			logger.Log.Warn("echo suppression is not supported with the device codec",
+				slog.String("component", "audio_streamer"),
+				slog.String("device_codec", s.deviceCodec.Name))
+		}
+	}
+
 	// Convert to Hikvision AudioSession
 	hikSession := &hikvision.AudioSession{
 		ChannelID: sess.ChannelID,
 		SessionID: sess.SessionID,
 	}
+	reconnect := hikvision.ReconnectPolicy{
+		Reopen:  s.reopen,
+		OnEvent: s.reportReconnect,
+	}
 
 	// Create and start audio writer (for sending to doorbell)
-	s.audioWriter = s.client.NewAudioStreamWriter(hikSession)
-	s.audioWriter.Start()
+	writer := s.client.NewAudioStreamWriter(hikSession)
+	writer.SetMaxLatency(clientToDeviceMaxLatency)
+	writer.SetCodec(s.deviceCodec)
+	writer.SetSilence(s.deviceCodec)
+	writer.SetReconnect(reconnect)
+	writer.Start(ctx)
+	s.reopenMu.Lock()
+	s.audioWriter = writer
+	s.reopenMu.Unlock()
+
+	// The level of live audio is followed as it plays
+	s.speaker = s.audioWriter
+	if s.processing.Enabled() {
+		speaker, err := audio.NewProcessedWriter(s.audioWriter, s.deviceCodec, audio.NewProcessor(s.processing))
+		if err != nil {
+			logger.Log.Warn("client audio is sent unprocessed",
+				slog.String("component", "audio_streamer"),
+				slog.String("error", err.Error()))
+		} else {
+			s.speaker = speaker
+		}
+	}
 
 	// Create and start audio reader (for receiving from doorbell)
 	s.audioReader = s.client.NewAudioStreamReader(hikSession)
-	s.audioReader.Start()
+	s.audioReader.SetReconnect(reconnect)
+	s.audioReader.Start(ctx)
 
 	logger.Log.Info("started audio streaming session",
 		slog.String("component", "audio_streamer"),
-		slog.String("channel_id", sess.ChannelID))
+		slog.String("channel_id", sess.ChannelID),
+		slog.String("device_codec", s.deviceCodec.Name),
+		slog.String("client_codec", s.clientCodec.Name))
 
 	return nil
 }
 
+// reopen re-opens the device channel for a reconnecting stream. When both streams
+// drop together, the second one gets the session re-opened for the first.
+func (s *HikvisionAudioStreamer) reopen(ctx context.Context) (*hikvision.AudioSession, error) {
+	s.reopenMu.Lock()
+	defer s.reopenMu.Unlock()
+
+	if time.Since(s.reopenedAt) >= reopenDebounce {
+		sess, err := s.sessionManager.ReopenChannel(ctx, s.session)
+		if err != nil {
+			return nil, err
+		}
+		s.session = sess
+		s.reopenedAt = time.Now()
+	}
+
+	return &hikvision.AudioSession{
+		ChannelID: s.session.ChannelID,
+		SessionID: s.session.SessionID,
+	}, nil
+}
+
+// reportReconnect logs a device stream reconnect and passes it on to the owner
+func (s *HikvisionAudioStreamer) reportReconnect(ev hikvision.ReconnectEvent) {
+	event := ReconnectEvent{
+		Stream:    ev.Stream,
+		ChannelID: ev.ChannelID,
+		State:     string(ev.State),
+		Attempt:   ev.Attempt,
+	}
+	if ev.Err != nil {
+		event.Error = ev.Err.Error()
+	}
+
+	logger.Log.Warn("device audio stream reconnect",
+		slog.String("component", "audio_streamer"),
+		slog.String("stream", event.Stream),
+		slog.String("state", event.State),
+		slog.Int("attempt", event.Attempt),
+		slog.String("error", event.Error))
+
+	if s.onReconnect != nil {
+		s.onReconnect(event)
+	}
+}
+
 // StreamDeviceToClient reads audio from the device and sends to WebRTC client
 func (s *HikvisionAudioStreamer) StreamDeviceToClient(ctx context.Context, track *webrtc.TrackLocalStaticSample) error {
 	defer logger.Log.Info("stopped streaming device to client",
 		slog.String("component", "audio_streamer"))
 
-	buffer := make([]byte, audio.SampleSize)
+	buffer := make([]byte, s.deviceCodec.FrameSize())
 
 	for {
 		select {
@@ -64,7 +202,7 @@ func (s *HikvisionAudioStreamer) StreamDeviceToClient(ctx context.Context, track
 				slog.String("component", "audio_streamer"))
 			return ctx.Err()
 		default:
-			// Read exactly audio.SampleSize bytes from device
+			// Read exactly one 20 ms frame from device
 			n, err := io.ReadFull(s.audioReader, buffer)
 			if err != nil {
 				if err != io.EOF && err != io.ErrUnexpectedEOF {
@@ -75,9 +213,21 @@ func (s *HikvisionAudioStreamer) StreamDeviceToClient(ctx context.Context, track
 				return err
 			}
 
+			data := buffer[:n]
+			if s.echo != nil {
+				if data, err = s.suppressEcho(data); err != nil {
+					return err
+				}
+			}
+
+			data, err = s.toClient.Transcode(data)
+			if err != nil {
+				return err
+			}
+
 			// Send to WebRTC track with precise timing
 			if err := track.WriteSample(media.Sample{
-				Data:     buffer[:n],
+				Data:     data,
 				Duration: audio.SampleDuration,
 			}); err != nil {
 				logger.Log.Error("error sending audio sample to client",
@@ -94,6 +244,15 @@ func (s *HikvisionAudioStreamer) StreamClientToDevice(ctx context.Context, track
 	defer logger.Log.Info("stopped streaming client to device",
 		slog.String("component", "audio_streamer"))
 
+	clientCodec, ok := audio.CodecByMimeType(track.Codec().MimeType)
+	if !ok {
+		return fmt.Errorf("unsupported client codec %q", track.Codec().MimeType)
+	}
+	toDevice, err := audio.NewTranscoder(clientCodec, s.deviceCodec)
+	if err != nil {
+		return fmt.Errorf("client codec %s: %w", clientCodec.Name, err)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -111,8 +270,24 @@ func (s *HikvisionAudioStreamer) StreamClientToDevice(ctx context.Context, track
 				return err
 			}
 
+			payload, err := toDevice.Transcode(rtp.Payload)
+			if err != nil {
+				// A packet that does not decode is lost audio, not a reason to end the session
+				logger.Log.Debug("dropping client audio packet",
+					slog.String("component", "audio_streamer"),
+					slog.String("error", err.Error()))
+				continue
+			}
+
+			// Watch for speech, before processing changes its level
+			if s.echo != nil {
+				if samples, err := audio.Decode(s.deviceCodec, payload); err == nil {
+					s.echo.Client(samples)
+				}
+			}
+
 			// Send audio payload to device
-			_, err = s.audioWriter.Write(rtp.Payload)
+			_, err = s.speaker.Write(payload)
 			if err != nil {
 				logger.Log.Error("error writing audio to device",
 					slog.String("component", "audio_streamer"),
@@ -123,11 +298,48 @@ func (s *HikvisionAudioStreamer) StreamClientToDevice(ctx context.Context, track
 	}
 }
 
+// suppressEcho attenuates device audio while the client speaks
+func (s *HikvisionAudioStreamer) suppressEcho(data []byte) ([]byte, error) {
+	samples, err := audio.Decode(s.deviceCodec, data)
+	if err != nil {
+		return nil, err
+	}
+	if !s.echo.Device(samples) {
+		return data, nil
+	}
+	return audio.Encode(s.deviceCodec, samples)
+}
+
+// Stats reports the state of the streaming session
+func (s *HikvisionAudioStreamer) Stats() SessionStats {
+	stats := SessionStats{
+		DeviceCodec: s.deviceCodec.Name,
+		ClientCodec: s.clientCodec.Name,
+	}
+
+	s.reopenMu.Lock()
+	if s.session != nil {
+		stats.ChannelID = s.session.ChannelID
+	}
+	if s.audioWriter != nil {
+		stats.Speaker = s.audioWriter.Stats()
+	}
+	s.reopenMu.Unlock()
+
+	if s.echo != nil {
+		stats.EchoSuppression = s.echo.Stats()
+	}
+	return stats
+}
+
 // Stop closes the streaming session
 func (s *HikvisionAudioStreamer) Stop() error {
-	if s.audioWriter != nil {
-		s.audioWriter.Close()
-		s.audioWriter = nil
+	s.reopenMu.Lock()
+	writer := s.audioWriter
+	s.audioWriter = nil
+	s.reopenMu.Unlock()
+	if writer != nil {
+		writer.Close()
 	}
 
 	if s.audioReader != nil {
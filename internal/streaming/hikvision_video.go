@@ -0,0 +1,152 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
+	"github.com/acardace/hikvision-doorbell-server/internal/logger"
+	"github.com/acardace/hikvision-doorbell-server/internal/rtsp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// videoTimeout bounds RTSP requests and the wait for the next video packet
+	videoTimeout = 10 * time.Second
+
+	// videoKeepAliveInterval keeps the RTSP session from timing out (usually after 60s)
+	videoKeepAliveInterval = 30 * time.Second
+
+	// videoRetryDelay is the pause before reconnecting a dropped RTSP stream
+	videoRetryDelay = 5 * time.Second
+)
+
+// HikvisionVideoStreamer implements VideoStreamer by relaying the H.264 RTP
+// packets of a device RTSP stream, without decoding them
+type HikvisionVideoStreamer struct {
+	client   *hikvision.Client
+	stream   hikvision.VideoStream
+	rtspPort int
+}
+
+// NewHikvisionVideoStreamer creates a video streamer for the given device stream.
+// A zero rtspPort uses the default RTSP port.
+func NewHikvisionVideoStreamer(client *hikvision.Client, stream hikvision.VideoStream, rtspPort int) *HikvisionVideoStreamer {
+	return &HikvisionVideoStreamer{
+		client:   client,
+		stream:   stream,
+		rtspPort: rtspPort,
+	}
+}
+
+// StreamToTrack sends the device video to the WebRTC client until ctx is done,
+// reconnecting if the RTSP stream drops
+func (s *HikvisionVideoStreamer) StreamToTrack(ctx context.Context, track *webrtc.TrackLocalStaticRTP) error {
+	defer logger.Log.Info("stopped streaming video to client",
+		slog.String("component", "video_streamer"))
+
+	for {
+		// Do not try the credentials while the device is rejecting them
+		if s.client.AuthStatus().LockedOut {
+			return hikvision.ErrAuthLockedOut
+		}
+
+		err := s.relay(ctx, track)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, rtsp.ErrUnauthorized) {
+			logger.Log.Error("device rejected the RTSP credentials",
+				slog.String("component", "video_streamer"))
+			return err
+		}
+
+		logger.Log.Warn("video stream interrupted, reconnecting",
+			slog.String("component", "video_streamer"),
+			slog.String("error", err.Error()),
+			slog.Duration("delay", videoRetryDelay))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(videoRetryDelay):
+		}
+	}
+}
+
+// relay runs a single RTSP session, forwarding its packets to the track
+func (s *HikvisionVideoStreamer) relay(ctx context.Context, track *webrtc.TrackLocalStaticRTP) error {
+	source, username, password, err := s.client.RTSPSource(s.stream, s.rtspPort)
+	if err != nil {
+		return err
+	}
+
+	conn, err := rtsp.Dial(ctx, source, username, password, videoTimeout)
+	if err != nil {
+		return err
+	}
+
+	// Closing the connection unblocks ReadPacket when the session ends
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		conn.Close()
+	}()
+
+	desc, base, err := conn.Describe()
+	if err != nil {
+		return err
+	}
+	control, _, err := rtsp.FindTrack(desc, base, "video", "H264")
+	if err != nil {
+		return err
+	}
+	if err := conn.Setup(control, 0); err != nil {
+		return err
+	}
+	if err := conn.Play(); err != nil {
+		return err
+	}
+
+	logger.Log.Info("started streaming video to client",
+		slog.String("component", "video_streamer"),
+		slog.String("stream", string(s.stream)))
+
+	keepAlive := time.NewTicker(videoKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	var packet rtp.Packet
+	for {
+		select {
+		case <-keepAlive.C:
+			if err := conn.KeepAlive(); err != nil {
+				return err
+			}
+		default:
+		}
+
+		channel, payload, err := conn.ReadPacket()
+		if err != nil {
+			return err
+		}
+		// Channel 1 carries the RTCP of the camera, which the browser does not need
+		if channel != 0 {
+			continue
+		}
+
+		if err := packet.Unmarshal(payload); err != nil {
+			continue
+		}
+		// The track rewrites the payload type and SSRC negotiated with the client
+		if err := track.WriteRTP(&packet); err != nil {
+			return err
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 
+	"github.com/acardace/hikvision-doorbell-server/internal/hikvision"
 	"github.com/acardace/hikvision-doorbell-server/internal/session"
 	"github.com/pion/webrtc/v4"
 )
@@ -22,18 +23,45 @@ type AudioStreamer interface {
 
 	// Stop closes the streaming session
 	Stop() error
+
+	// Stats reports the state of the streaming session
+	Stats() SessionStats
+}
+
+// SessionStats reports the state of an audio streaming session
+type SessionStats struct {
+	ChannelID       string
+	DeviceCodec     string
+	ClientCodec     string
+	EchoSuppression EchoSuppressionStats
+	Speaker         hikvision.AudioStreamWriterStats // Pacing of the audio sent to the device speaker
+}
+
+// ReconnectEvent reports the reconnect of a dropped device audio stream
+type ReconnectEvent struct {
+	Stream    string // "reader" (device microphone) or "writer" (device speaker)
+	ChannelID string
+	State     string // "reconnecting", "reconnected" or "failed"
+	Attempt   int
+	Error     string // Why the stream dropped or the attempt failed
+}
+
+// VideoStreamer forwards the device video to WebRTC
+type VideoStreamer interface {
+	// StreamToTrack sends the device video to the WebRTC client until ctx is done
+	StreamToTrack(ctx context.Context, track *webrtc.TrackLocalStaticRTP) error
 }
 
 // AudioReader represents a source of audio data (doorbell microphone)
 type AudioReader interface {
 	io.Reader
-	Start()
+	Start(ctx context.Context)
 	Close() error
 }
 
 // AudioWriter represents a sink for audio data (doorbell speaker)
 type AudioWriter interface {
 	io.Writer
-	Start()
+	Start(ctx context.Context)
 	Close() error
 }
@@ -0,0 +1,86 @@
+package tts
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+)
+
+// DefaultCacheSize is the number of phrases a Cache keeps by default
+const DefaultCacheSize = 100
+
+// Cache keeps the audio of recently synthesized phrases, so repeated ones play
+// without waiting for the provider. Phrases are keyed by a hash of the text,
+// voice and language, and the least recently used are evicted first.
+type Cache struct {
+	provider TTSProvider
+	size     int
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List // Of *cacheEntry, most recently used first
+}
+
+type cacheEntry struct {
+	key   [sha256.Size]byte
+	audio []byte
+}
+
+// NewCache wraps provider with a cache of size phrases (DefaultCacheSize if zero)
+func NewCache(provider TTSProvider, size int) *Cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &Cache{
+		provider: provider,
+		size:     size,
+		entries:  make(map[[sha256.Size]byte]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Synthesize returns the cached audio of a request, synthesizing it if needed
+func (c *Cache) Synthesize(ctx context.Context, req Request) ([]byte, error) {
+	audio, _, err := c.Lookup(ctx, req)
+	return audio, err
+}
+
+// Lookup is Synthesize that also reports whether the audio came from the cache
+func (c *Cache) Lookup(ctx context.Context, req Request) ([]byte, bool, error) {
+	key := cacheKey(req)
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		audio := el.Value.(*cacheEntry).audio
+		c.mu.Unlock()
+		return audio, true, nil
+	}
+	c.mu.Unlock()
+
+	// Synthesize without holding the lock; concurrent requests for a new
+	// phrase may synthesize it twice, which is harmless
+	audio, err := c.provider.Synthesize(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, audio: audio})
+		for c.lru.Len() > c.size {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return audio, false, nil
+}
+
+// cacheKey hashes the fields of a request, length-prefixed so they cannot run into each other
+func cacheKey(req Request) [sha256.Size]byte {
+	return sha256.Sum256(fmt.Appendf(nil, "%d:%s%d:%s%s", len(req.Voice), req.Voice, len(req.Language), req.Language, req.Text))
+}
@@ -0,0 +1,136 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Placeholders replaced in the arguments of a CommandProvider
+const (
+	PlaceholderText     = "{text}"
+	PlaceholderVoice    = "{voice}"
+	PlaceholderLanguage = "{language}"
+	PlaceholderOutput   = "{output}"
+)
+
+// CommandProvider synthesizes speech with a local binary such as piper or espeak-ng.
+//
+// The text is written to the standard input of the command, unless an argument
+// holds {text}; then text starting with '-' is rejected, so it cannot pass as
+// an option. The voice and language of a request must be names (see safeName),
+// as they end up in arguments or model paths. The audio is read from the standard output, unless an argument
+// holds {output}: then it is read from the file of that name once the command exits.
+type CommandProvider struct {
+	command []string
+	voice   string
+	lang    string
+	timeout time.Duration
+}
+
+// NewCommandProvider creates a provider running command, the binary followed by
+// its arguments. voice and lang are used when a request does not set them, and
+// the command is killed after timeout (default 30s).
+func NewCommandProvider(command []string, voice, lang string, timeout time.Duration) (*CommandProvider, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("no TTS command given")
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	return &CommandProvider{command: command, voice: voice, lang: lang, timeout: timeout}, nil
+}
+
+// Synthesize runs the command for a request
+func (p *CommandProvider) Synthesize(ctx context.Context, req Request) ([]byte, error) {
+	if p.uses(PlaceholderText) && strings.HasPrefix(req.Text, "-") {
+		return nil, fmt.Errorf("%w: text passed as an argument cannot start with '-'", ErrInvalidRequest)
+	}
+	if req.Voice != "" && !safeName(req.Voice) {
+		return nil, fmt.Errorf("%w: invalid voice %q", ErrInvalidRequest, req.Voice)
+	}
+	if req.Language != "" && !safeName(req.Language) {
+		return nil, fmt.Errorf("%w: invalid language %q", ErrInvalidRequest, req.Language)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	voice, lang := req.Voice, req.Language
+	if voice == "" {
+		voice = p.voice
+	}
+	if lang == "" {
+		lang = p.lang
+	}
+
+	var output string
+	if p.uses(PlaceholderOutput) {
+		dir, err := os.MkdirTemp("", "tts-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		output = filepath.Join(dir, "speech.wav")
+	}
+
+	replacer := strings.NewReplacer(
+		PlaceholderText, req.Text,
+		PlaceholderVoice, voice,
+		PlaceholderLanguage, lang,
+		PlaceholderOutput, output,
+	)
+	args := make([]string, len(p.command)-1)
+	for i, arg := range p.command[1:] {
+		args[i] = replacer.Replace(arg)
+	}
+
+	cmd := exec.CommandContext(ctx, p.command[0], args...)
+	if !p.uses(PlaceholderText) {
+		cmd.Stdin = strings.NewReader(req.Text)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", p.command[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	data := stdout.Bytes()
+	if output != "" {
+		var err error
+		if data, err = os.ReadFile(output); err != nil {
+			return nil, fmt.Errorf("%s wrote no audio: %w", p.command[0], err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, ErrEmptyAudio
+	}
+	return data, nil
+}
+
+// namePattern is the character set of voice and language names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// safeName reports whether a voice or language name from a request can be put
+// in an argument: it cannot be an option, leave a directory or hold separators
+func safeName(name string) bool {
+	return namePattern.MatchString(name) && !strings.HasPrefix(name, "-") && !strings.Contains(name, "..")
+}
+
+// uses reports whether an argument holds a placeholder
+func (p *CommandProvider) uses(placeholder string) bool {
+	for _, arg := range p.command[1:] {
+		if strings.Contains(arg, placeholder) {
+			return true
+		}
+	}
+	return false
+}
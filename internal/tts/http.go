@@ -0,0 +1,146 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxAudioSize bounds the audio accepted from an HTTP provider
+const maxAudioSize = 10 << 20 // 10 MB, like play-file uploads
+
+// HTTPProvider synthesizes speech with an HTTP service.
+//
+// With POST (the default), the request is sent as a JSON body with the text,
+// voice and language fields; with GET, as query parameters of the same names.
+// The response body is the audio file.
+type HTTPProvider struct {
+	url     string
+	method  string
+	headers map[string]string
+	voice   string
+	lang    string
+	client  *http.Client
+}
+
+// HTTPProviderOptions configures an HTTPProvider
+type HTTPProviderOptions struct {
+	// Method is POST (default) or GET
+	Method string
+
+	// Headers are added to every request (e.g. Authorization)
+	Headers map[string]string
+
+	// Voice and Language are used when a request does not set them
+	Voice    string
+	Language string
+
+	// Timeout bounds each request (default 30s)
+	Timeout time.Duration
+}
+
+// NewHTTPProvider creates a provider calling the endpoint at rawURL
+func NewHTTPProvider(rawURL string, opts HTTPProviderOptions) (*HTTPProvider, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid TTS URL %q", rawURL)
+	}
+
+	method := strings.ToUpper(opts.Method)
+	switch method {
+	case "":
+		method = http.MethodPost
+	case http.MethodPost, http.MethodGet:
+	default:
+		return nil, fmt.Errorf("unsupported TTS method %q (use POST or GET)", opts.Method)
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &HTTPProvider{
+		url:     rawURL,
+		method:  method,
+		headers: opts.Headers,
+		voice:   opts.Voice,
+		lang:    opts.Language,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// httpRequest is the JSON body of POST requests
+type httpRequest struct {
+	Text     string `json:"text"`
+	Voice    string `json:"voice,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// Synthesize calls the endpoint for a request
+func (p *HTTPProvider) Synthesize(ctx context.Context, req Request) ([]byte, error) {
+	body := httpRequest{Text: req.Text, Voice: req.Voice, Language: req.Language}
+	if body.Voice == "" {
+		body.Voice = p.voice
+	}
+	if body.Language == "" {
+		body.Language = p.lang
+	}
+
+	var httpReq *http.Request
+	var err error
+	if p.method == http.MethodGet {
+		u, _ := url.Parse(p.url)
+		query := u.Query()
+		query.Set("text", body.Text)
+		if body.Voice != "" {
+			query.Set("voice", body.Voice)
+		}
+		if body.Language != "" {
+			query.Set("language", body.Language)
+		}
+		u.RawQuery = query.Encode()
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	} else {
+		data, _ := json.Marshal(body)
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(data))
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range p.headers {
+		httpReq.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("TTS service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAudioSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAudioSize {
+		return nil, fmt.Errorf("TTS audio larger than %d bytes", maxAudioSize)
+	}
+	if len(data) == 0 {
+		return nil, ErrEmptyAudio
+	}
+	return data, nil
+}
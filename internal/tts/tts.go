@@ -0,0 +1,30 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// defaultTimeout bounds a synthesis when the provider is not given a timeout
+const defaultTimeout = 30 * time.Second
+
+// ErrEmptyAudio is returned when a provider produced no audio
+var ErrEmptyAudio = errors.New("no audio synthesized")
+
+// ErrInvalidRequest is returned for a request a provider cannot safely pass on
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Request is a piece of text to speak
+type Request struct {
+	Text     string
+	Voice    string // Provider-specific voice name, empty for the default
+	Language string // Language code (e.g. "en"), empty for the default
+}
+
+// TTSProvider synthesizes speech from text.
+// This interface allows for different backends (local binaries, HTTP services, ...)
+type TTSProvider interface {
+	// Synthesize returns the speech as an audio file (WAV, MP3, FLAC or Ogg Vorbis)
+	Synthesize(ctx context.Context, req Request) ([]byte, error)
+}